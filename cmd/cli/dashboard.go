@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os/exec"
 	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -11,8 +12,9 @@ import (
 var dashboardCmd = &cobra.Command{
 	Use:   "dashboard",
 	Short: "Open the web dashboard",
+	Long:  "opens the dashboard served by the gateway (see --gateway) in the browser.",
 	Run: func(cmd *cobra.Command, args []string) {
-		url := "http://localhost:3000"
+		url := strings.TrimRight(gatewayURL, "/") + "/admin/dashboard/"
 		fmt.Printf("Opening dashboard at %s...\n", url)
 		openBrowser(url)
 	},
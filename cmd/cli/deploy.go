@@ -1,23 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/nikhi/nanolambda/pkg/deploy"
+	"github.com/nikhi/nanolambda/pkg/docker"
 	"github.com/nikhi/nanolambda/pkg/registry"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 )
 
-type FunctionConfig struct {
-	Name    string `yaml:"name"`
-	Runtime string `yaml:"runtime"`
-	Timeout int    `yaml:"timeout"`
-}
-
 var deployCmd = &cobra.Command{
 	Use:   "deploy [path]",
 	Short: "Deploy a function",
@@ -29,37 +29,28 @@ var deployCmd = &cobra.Command{
 		}
 
 		// 1. Read config
-		configFile := filepath.Join(path, "nanolambda.yaml")
-		data, err := os.ReadFile(configFile)
+		config, err := deploy.LoadConfig(path)
 		if err != nil {
-			fmt.Printf("Error reading nanolambda.yaml: %v\n", err)
-			return
-		}
-
-		var config FunctionConfig
-		if err := yaml.Unmarshal(data, &config); err != nil {
-			fmt.Printf("Error parsing nanolambda.yaml: %v\n", err)
+			fmt.Printf("%v\n", err)
 			return
 		}
 
 		fmt.Printf("Deploying function '%s'...\n", config.Name)
 
-		// 2. Build Docker Image
-		imageTag := fmt.Sprintf("nanolambda/%s:latest", config.Name)
-		fmt.Printf("Building image %s...\n", imageTag)
-		
-		buildCmd := exec.Command("docker", "build", "-t", imageTag, path)
-		buildCmd.Stdout = os.Stdout
-		buildCmd.Stderr = os.Stderr
-		if err := buildCmd.Run(); err != nil {
-			fmt.Printf("Docker build failed: %v\n", err)
+		if remote, _ := cmd.Flags().GetBool("remote"); remote {
+			deployRemote(path, config.Name)
 			return
 		}
 
-		// 3. Register Function
+		// 2. Connect to Docker and the Registry
 		// Assuming we share the DB file with Gateway (single machine)
-		// We need to know where the DB is. Let's assume ./data/nanolambda.db relative to where CLI is run?
-		// Or strictly use an absolute path or flag. For demo: ./data/nanolambda.db
+		// For demo: ./data/nanolambda.db relative to where CLI is run
+		d, err := docker.NewManager()
+		if err != nil {
+			fmt.Printf("Error connecting to Docker: %v\n", err)
+			return
+		}
+
 		os.MkdirAll("./data", 0755)
 		reg, err := registry.NewManager("./data/nanolambda.db")
 		if err != nil {
@@ -68,24 +59,131 @@ var deployCmd = &cobra.Command{
 		}
 		defer reg.Close()
 
-		fn := registry.Function{
-			Name:        config.Name,
-			Runtime:     config.Runtime,
-			ImageTag:    imageTag,
-			CreatedAt:   time.Now(),
-			MemoryLimit: 128, // Default
-			Timeout:     config.Timeout,
+		// 3. Build the image and register the function
+		// Build output is streamed here and stored with the deployment
+		artifacts, err := deploy.NewArtifactStore("./data/artifacts")
+		if err != nil {
+			fmt.Printf("Error opening artifact store: %v\n", err)
+			return
 		}
 
-		if err := reg.RegisterFunction(fn); err != nil {
-			fmt.Printf("Error registering function: %v\n", err)
+		deployer := &deploy.Deployer{Docker: d, Registry: reg, Artifacts: artifacts}
+		dep, err := deployer.Deploy(context.Background(), path, os.Stdout)
+		if err != nil {
+			if dep != nil {
+				fmt.Printf("Deployment %s failed: %v\n", dep.ID, err)
+			} else {
+				fmt.Printf("Deployment failed: %v\n", err)
+			}
 			return
 		}
 
-		fmt.Println("Function deployed successfully!")
+		printDeployed(dep.ID, dep.Version, dep.ImageDigest, dep.Artifact)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(deployCmd)
+	deployCmd.Flags().Bool("remote", false, "Upload the function and build it on the gateway")
+}
+
+// deployRemote uploads the function directory to the gateway and follows the build
+func deployRemote(path, name string) {
+	// 1. Package the directory
+	var bundle bytes.Buffer
+	if err := deploy.PackBundle(path, &bundle); err != nil {
+		fmt.Printf("Error packaging function: %v\n", err)
+		return
+	}
+	fmt.Printf("Uploading %d bytes to %s...\n", bundle.Len(), gatewayURL)
+
+	// 2. Upload it
+	resp, err := http.Post(fmt.Sprintf("%s/admin/functions/%s/deploy", gatewayURL, url.PathEscape(name)), "application/gzip", &bundle)
+	if err != nil {
+		fmt.Printf("Error contacting gateway: %v\n", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		msg, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Upload rejected (%s): %s\n", resp.Status, strings.TrimSpace(string(msg)))
+		return
+	}
+
+	var dep remoteDeployment
+	if err := json.NewDecoder(resp.Body).Decode(&dep); err != nil {
+		fmt.Printf("Error reading gateway response: %v\n", err)
+		return
+	}
+	fmt.Printf("Deployment %s started\n", dep.ID)
+
+	// 3. Stream the build logs until the build finishes
+	logs, err := http.Get(gatewayURL + dep.LogsURL + "?follow=true")
+	if err == nil {
+		io.Copy(os.Stdout, logs.Body)
+		logs.Body.Close()
+	}
+
+	// 4. Poll for the final status (the log stream may have been cut short)
+	for {
+		if err := getJSON(gatewayURL+dep.StatusURL, &dep); err != nil {
+			fmt.Printf("Error checking deployment status: %v\n", err)
+			return
+		}
+		if dep.Status != registry.DeploymentBuilding {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+
+	if dep.Status != registry.DeploymentSucceeded {
+		fmt.Printf("Deployment %s failed: %s\n", dep.ID, dep.Error)
+		return
+	}
+	printDeployed(dep.ID, dep.Version, dep.ImageDigest, dep.Artifact)
+}
+
+func printDeployed(id string, version int, imageDigest, artifact string) {
+	if artifact != "" {
+		fmt.Printf("Function deployed successfully! (deployment %s, version %d, code %s)\n", id, version, shortDigest(artifact))
+		return
+	}
+	fmt.Printf("Function deployed successfully! (deployment %s, version %d, image %s)\n", id, version, shortDigest(imageDigest))
+}
+
+// remoteDeployment mirrors the gateway's deployment response
+type remoteDeployment struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	ImageDigest string `json:"image_digest"`
+	Artifact    string `json:"artifact"`
+	Version     int    `json:"version"`
+	Error       string `json:"error"`
+	StatusURL   string `json:"status_url"`
+	LogsURL     string `json:"logs_url"`
+}
+
+// getJSON fetches target and decodes the JSON response into v
+func getJSON(target string, v interface{}) error {
+	resp, err := http.Get(target)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// shortDigest trims an image digest to the 12 characters docker shows
+func shortDigest(digest string) string {
+	const prefix = "sha256:"
+	if len(digest) > len(prefix)+12 {
+		return digest[len(prefix) : len(prefix)+12]
+	}
+	return digest
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// functionDescription mirrors the gateway's GET /admin/functions/{name} response
+type functionDescription struct {
+	Name         string    `json:"name"`
+	Version      int       `json:"version"`
+	Runtime      string    `json:"runtime"`
+	Image        string    `json:"image"`
+	Artifact     string    `json:"artifact"`
+	Timeout      int       `json:"timeout"`
+	MemoryLimit  int64     `json:"memory_limit"`
+	MinInstances int       `json:"min_instances"`
+	MaxInstances int       `json:"max_instances"`
+	DeployedAt   time.Time `json:"deployed_at"`
+	Replicas     []struct {
+		ID        string    `json:"id"`
+		State     string    `json:"state"`
+		Origin    string    `json:"origin"`
+		InFlight  int       `json:"in_flight"`
+		Served    int       `json:"served"`
+		StartedAt time.Time `json:"started_at"`
+		IdleFor   string    `json:"idle_for"`
+	} `json:"replicas"`
+	Breaker struct {
+		State         string    `json:"state"`
+		StartFailures int       `json:"consecutive_start_failures"`
+		ErrorRate     float64   `json:"error_rate"`
+		LastFailure   string    `json:"last_failure"`
+		NextProbe     time.Time `json:"next_probe"`
+	} `json:"breaker"`
+	Usage *struct {
+		WorkingSetBytes uint64  `json:"working_set_bytes"`
+		PeakBytes       uint64  `json:"peak_bytes"`
+		CPUSeconds      float64 `json:"cpu_seconds"`
+		Sizing          string  `json:"sizing"`
+	} `json:"usage"`
+}
+
+var describeCmd = &cobra.Command{
+	Use:   "describe [function]",
+	Short: "Show a function's configuration, replicas and health",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var d functionDescription
+		target := fmt.Sprintf("%s/admin/functions/%s", strings.TrimRight(gatewayURL, "/"), url.PathEscape(args[0]))
+		if err := getJSON(target, &d); err != nil {
+			fmt.Printf("Error describing function: %v\n", err)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintf(w, "Name:\t%s\n", d.Name)
+		fmt.Fprintf(w, "Version:\t%d\n", d.Version)
+		fmt.Fprintf(w, "Runtime:\t%s\n", d.Runtime)
+		fmt.Fprintf(w, "Image:\t%s\n", d.Image)
+		if d.Artifact != "" {
+			fmt.Fprintf(w, "Artifact:\t%s\n", d.Artifact)
+		}
+		fmt.Fprintf(w, "Timeout:\t%ds\n", d.Timeout)
+		fmt.Fprintf(w, "Memory:\t%d MB\n", d.MemoryLimit)
+		if u := d.Usage; u != nil {
+			usage := fmt.Sprintf("%d MB now, %d MB peak, %.1f cpu seconds", u.WorkingSetBytes>>20, u.PeakBytes>>20, u.CPUSeconds)
+			switch u.Sizing {
+			case "oversized":
+				usage += " (limit could be lowered)"
+			case "near_limit":
+				usage += " (close to the limit)"
+			}
+			fmt.Fprintf(w, "Usage:\t%s\n", usage)
+		}
+		fmt.Fprintf(w, "Min Instances:\t%d\n", d.MinInstances)
+		if d.MaxInstances > 0 {
+			fmt.Fprintf(w, "Max Instances:\t%d\n", d.MaxInstances)
+		}
+		fmt.Fprintf(w, "Deployed:\t%s\n", d.DeployedAt.Format(time.RFC3339))
+
+		breaker := d.Breaker.State
+		switch {
+		case d.Breaker.State != "closed":
+			breaker += fmt.Sprintf(" (next probe %s)", d.Breaker.NextProbe.Format(time.TimeOnly))
+		case d.Breaker.StartFailures > 0:
+			breaker += fmt.Sprintf(" (%d failed starts)", d.Breaker.StartFailures)
+		}
+		fmt.Fprintf(w, "Circuit:\t%s\n", breaker)
+		if d.Breaker.LastFailure != "" {
+			fmt.Fprintf(w, "Last Failure:\t%s\n", d.Breaker.LastFailure)
+		}
+		fmt.Fprintf(w, "Error Rate:\t%.0f%%\n", d.Breaker.ErrorRate*100)
+		w.Flush()
+
+		fmt.Printf("\nReplicas: %d\n", len(d.Replicas))
+		if len(d.Replicas) == 0 {
+			return
+		}
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATE\tORIGIN\tIN FLIGHT\tSERVED\tUPTIME\tIDLE")
+		for _, r := range d.Replicas {
+			uptime := time.Since(r.StartedAt).Round(time.Second)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n", r.ID, r.State, r.Origin, r.InFlight, r.Served, uptime, r.IdleFor)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(describeCmd)
+}
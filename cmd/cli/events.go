@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/events"
+	"github.com/spf13/cobra"
+)
+
+// streamEvents reads a Server-Sent Events stream and calls handle for every event
+func streamEvents(body io.Reader, handle func(events.Event)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() > 0 {
+				var e events.Event
+				if err := json.Unmarshal([]byte(data.String()), &e); err == nil {
+					handle(e)
+				}
+				data.Reset()
+			}
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// id and event lines repeat what's in the data, comments are heartbeats
+	}
+	return scanner.Err()
+}
+
+// printEvent prints an event as one line: time, type, function and whatever details it has
+func printEvent(e events.Event) {
+	var details []string
+	add := func(key, value string) {
+		if value != "" {
+			details = append(details, key+"="+value)
+		}
+	}
+	add("container", e.Container)
+	add("origin", e.Origin)
+	add("reason", e.Reason)
+	if e.Replicas > 0 {
+		add("replicas", fmt.Sprint(e.Replicas))
+	}
+	add("deployment", e.Deployment)
+	if e.Version > 0 {
+		add("version", fmt.Sprint(e.Version))
+	}
+	add("status", e.Status)
+	if e.DurationMS > 0 {
+		add("duration", (time.Duration(e.DurationMS * float64(time.Millisecond))).Round(time.Millisecond).String())
+	}
+	if e.Error != "" {
+		add("error", fmt.Sprintf("%q", e.Error))
+	}
+
+	function := e.Function
+	if function == "" {
+		function = "-"
+	}
+	fmt.Printf("%s  %-21s %-20s %s\n", e.Time.Local().Format("15:04:05.000"), e.Type, function, strings.Join(details, " "))
+}
+
+var eventsCmd = &cobra.Command{
+	Use:   "events [function]",
+	Short: "Show container, deploy and breaker events from the gateway",
+	Long: `shows the gateway's recent lifecycle events: cold starts, warmups, registered, reaped and
+crashed containers, deploys and circuit breakers. with --follow new events are streamed as they happen.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		follow, _ := cmd.Flags().GetBool("follow")
+		types, _ := cmd.Flags().GetString("type")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		query := url.Values{}
+		if len(args) == 1 {
+			query.Set("function", args[0])
+		}
+		if types != "" {
+			query.Set("type", types)
+		}
+		if !follow {
+			query.Set("follow", "false")
+		}
+
+		handle := printEvent
+		if asJSON {
+			handle = func(e events.Event) {
+				data, _ := json.Marshal(e)
+				fmt.Println(string(data))
+			}
+		}
+		var lastID uint64
+		track := func(e events.Event) {
+			lastID = e.ID
+			handle(e)
+		}
+
+		// reconnect when following, picking up after the last event seen
+		for {
+			if lastID > 0 {
+				query.Set("after", fmt.Sprint(lastID))
+			}
+			resp, err := http.Get(strings.TrimRight(gatewayURL, "/") + "/admin/events?" + query.Encode())
+			if err != nil {
+				fmt.Printf("Error connecting to gateway: %v\n", err)
+			} else if resp.StatusCode != http.StatusOK {
+				msg, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				fmt.Printf("Error reading events: %s: %s\n", resp.Status, strings.TrimSpace(string(msg)))
+				return
+			} else {
+				err = streamEvents(resp.Body, track)
+				resp.Body.Close()
+				if err != nil {
+					fmt.Printf("Event stream interrupted: %v\n", err)
+				}
+			}
+
+			if !follow {
+				return
+			}
+			time.Sleep(2 * time.Second)
+		}
+	},
+}
+
+func init() {
+	eventsCmd.Flags().BoolP("follow", "f", false, "Keep streaming new events")
+	eventsCmd.Flags().String("type", "", "Only these event types, comma separated (e.g. container.reaped,cold_start.end)")
+	eventsCmd.Flags().Bool("json", false, "Print events as json lines")
+	rootCmd.AddCommand(eventsCmd)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/registry"
+	"github.com/spf13/cobra"
+)
+
+// invocationFilter builds a history filter from the shared filter flags
+func invocationFilter(cmd *cobra.Command, function string) (registry.InvocationFilter, error) {
+	since, _ := cmd.Flags().GetDuration("since")
+	errorsOnly, _ := cmd.Flags().GetBool("errors")
+	status, _ := cmd.Flags().GetInt("status")
+	start, _ := cmd.Flags().GetString("start")
+	limit, _ := cmd.Flags().GetInt("limit")
+	requestID, _ := cmd.Flags().GetString("request-id")
+
+	f := registry.InvocationFilter{Function: function, RequestID: requestID, Errors: errorsOnly, Status: status, Start: start, Limit: limit}
+	if since > 0 {
+		f.Since = time.Now().Add(-since)
+	}
+	if start != "" && start != registry.StartCold && start != registry.StartWarm {
+		return f, fmt.Errorf("invalid --start %q: want cold or warm", start)
+	}
+	return f, nil
+}
+
+// addInvocationFilterFlags registers the flags read by invocationFilter
+func addInvocationFilterFlags(cmd *cobra.Command, limit int) {
+	cmd.Flags().Duration("since", 0, "Only invocations in the last duration, e.g. 1h")
+	cmd.Flags().Bool("errors", false, "Only invocations that returned a 5xx status")
+	cmd.Flags().Int("status", 0, "Only invocations with this status code")
+	cmd.Flags().String("start", "", "Only cold or warm invocations")
+	cmd.Flags().String("request-id", "", "Only invocations sent with this X-Request-ID")
+	cmd.Flags().Int("limit", limit, "Maximum number of invocations (0 for all)")
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history [function]",
+	Short: "List recent invocations of a function",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter, err := invocationFilter(cmd, args[0])
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+
+		reg, err := registry.NewManager("./data/nanolambda.db")
+		if err != nil {
+			fmt.Printf("Error connecting to registry: %v\n", err)
+			return
+		}
+		defer reg.Close()
+
+		invocations, err := reg.ListInvocations(filter)
+		if err != nil {
+			fmt.Printf("Error reading invocation history: %v\n", err)
+			return
+		}
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			exportInvocations(invocations)
+			return
+		}
+		if len(invocations) == 0 {
+			fmt.Println("No invocations found.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "ID\tREQUEST ID\tSTARTED\tVERSION\tSTATUS\tSTART\tDURATION\tCONTAINER\tPAYLOAD")
+		for _, inv := range invocations {
+			version, container, payload := "-", "-", "-"
+			if inv.Version > 0 {
+				version = fmt.Sprintf("v%d", inv.Version)
+			}
+			if inv.ContainerID != "" {
+				container = inv.ContainerID[:min(12, len(inv.ContainerID))]
+			}
+			if inv.HasPayload() {
+				payload = "yes"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n", inv.ID, inv.RequestID, inv.StartedAt.Local().Format(time.DateTime), version,
+				inv.Status, inv.Start, inv.Duration.Round(time.Millisecond), container, payload)
+		}
+		w.Flush()
+		fmt.Println("\nRun 'nanolambda history show <id>' for the request and response.")
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show [id]",
+	Short: "Show one invocation with its request and response bodies",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		reg, err := registry.NewManager("./data/nanolambda.db")
+		if err != nil {
+			fmt.Printf("Error connecting to registry: %v\n", err)
+			return
+		}
+		defer reg.Close()
+
+		inv, err := reg.GetInvocation(args[0])
+		if err != nil {
+			fmt.Printf("Invocation %s not found: %v\n", args[0], err)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintf(w, "ID:\t%s\n", inv.ID)
+		fmt.Fprintf(w, "Request ID:\t%s\n", inv.RequestID)
+		fmt.Fprintf(w, "Function:\t%s\n", inv.Function)
+		if inv.Version > 0 {
+			fmt.Fprintf(w, "Version:\t%d\n", inv.Version)
+		}
+		fmt.Fprintf(w, "Started:\t%s\n", inv.StartedAt.Local().Format(time.RFC3339Nano))
+		fmt.Fprintf(w, "Duration:\t%s\n", inv.Duration.Round(time.Microsecond))
+		fmt.Fprintf(w, "Status:\t%d\n", inv.Status)
+		fmt.Fprintf(w, "Start:\t%s\n", inv.Start)
+		if inv.ContainerID != "" {
+			fmt.Fprintf(w, "Container:\t%s\n", inv.ContainerID)
+		}
+		w.Flush()
+
+		if !inv.HasPayload() {
+			fmt.Println("\nBodies were not kept for this invocation (not sampled).")
+			return
+		}
+		printBody("Request", inv.RequestBody, inv.RequestTruncated)
+		printBody("Response", inv.ResponseBody, inv.ResponseTruncated)
+	},
+}
+
+// invocationLine is one invocation in a 'history --json' export, also the trace format of 'simulate'
+type invocationLine struct {
+	ID         string    `json:"id"`
+	RequestID  string    `json:"request_id"`
+	Function   string    `json:"function"`
+	Version    int       `json:"version,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS float64   `json:"duration_ms"`
+	Status     int       `json:"status"`
+	Start      string    `json:"start"`
+}
+
+// exportInvocations writes invocations as json lines, oldest first
+func exportInvocations(invocations []registry.Invocation) {
+	enc := json.NewEncoder(os.Stdout)
+	for i := len(invocations) - 1; i >= 0; i-- {
+		inv := invocations[i]
+		enc.Encode(invocationLine{
+			ID:         inv.ID,
+			RequestID:  inv.RequestID,
+			Function:   inv.Function,
+			Version:    inv.Version,
+			StartedAt:  inv.StartedAt,
+			DurationMS: float64(inv.Duration) / float64(time.Millisecond),
+			Status:     inv.Status,
+			Start:      inv.Start,
+		})
+	}
+}
+
+// printBody prints a stored body, indented if it is json
+func printBody(title string, body []byte, truncated bool) {
+	if truncated {
+		title += " (truncated)"
+	}
+	fmt.Printf("\n%s:\n", title)
+	var out bytes.Buffer
+	if json.Indent(&out, body, "", "  ") == nil {
+		body = out.Bytes()
+	}
+	fmt.Println(string(body))
+}
+
+func init() {
+	addInvocationFilterFlags(historyCmd, 20)
+	historyCmd.Flags().Bool("json", false, "Print one json line per invocation, oldest first, e.g. as a trace for 'simulate'")
+	historyCmd.AddCommand(historyShowCmd)
+	rootCmd.AddCommand(historyCmd)
+}
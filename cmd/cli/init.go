@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/nikhi/nanolambda/pkg/deploy"
 	"github.com/spf13/cobra"
 )
 
@@ -37,20 +38,15 @@ var initCmd = &cobra.Command{
 		}
 
 		// create dockerfile (dynamic generation based on runtime)
-		dockerfile := `FROM nanolambda/base-python:3.11
-
-COPY handler.py /function/handler.py
-# If you have requirements.txt, uncomment:
-# COPY requirements.txt /function/requirements.txt
-# RUN pip install -r /function/requirements.txt
-`
+		// deploys skip the image build while it is left unchanged
+		dockerfile := deploy.DefaultDockerfile
 		if err := os.WriteFile(filepath.Join(name, "Dockerfile"), []byte(dockerfile), 0644); err != nil {
 			fmt.Printf("error creating dockerfile: %v\n", err)
 			return
 		}
 
 		// create nanolambda.yaml (metadata)
-		yamlContent := fmt.Sprintf("name: %s\nruntime: %s\n", name, runtime)
+		yamlContent := fmt.Sprintf("name: %s\nruntime: %s\nmemory: 128\n", name, runtime)
 		if err := os.WriteFile(filepath.Join(name, "nanolambda.yaml"), []byte(yamlContent), 0644); err != nil {
 			fmt.Printf("error creating nanolambda.yaml: %v\n", err)
 			return
@@ -20,7 +20,16 @@ func main() {
 	}
 }
 
+// gatewayURL is the base URL of the gateway admin API
+var gatewayURL string
+
 func init() {
+	defaultGateway := os.Getenv("NANOLAMBDA_GATEWAY")
+	if defaultGateway == "" {
+		defaultGateway = "http://localhost:8080"
+	}
+	rootCmd.PersistentFlags().StringVar(&gatewayURL, "gateway", defaultGateway, "Gateway URL (or set NANOLAMBDA_GATEWAY)")
+
 	var versionCmd = &cobra.Command{
 		Use:   "version",
 		Short: "Print the version number",
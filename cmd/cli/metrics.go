@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
@@ -26,58 +27,96 @@ var metricsCmd = &cobra.Command{
 	Use:   "metrics",
 	Short: "Show system metrics",
 	Run: func(cmd *cobra.Command, args []string) {
-		promURL := "http://localhost:9090"
-		
+		promURL, _ := cmd.Flags().GetString("prometheus")
+
 		fmt.Println("Fetching metrics from Prometheus...")
 
-		// Helper to query prometheus
-		query := func(q string) float64 {
+		// Helper to query prometheus, returns the value of each series keyed by its function label
+		query := func(q string) (map[string]float64, error) {
 			resp, err := http.Get(fmt.Sprintf("%s/api/v1/query?query=%s", promURL, url.QueryEscape(q)))
 			if err != nil {
-				return 0
+				return nil, err
 			}
 			defer resp.Body.Close()
 
 			var p PrometheusResponse
 			if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
-				return 0
+				return nil, err
 			}
 
-			if len(p.Data.Result) > 0 && len(p.Data.Result[0].Value) > 1 {
+			out := make(map[string]float64)
+			for _, r := range p.Data.Result {
+				if len(r.Value) < 2 {
+					continue
+				}
 				// Parse string value to float
 				var val float64
-				fmt.Sscanf(p.Data.Result[0].Value[1].(string), "%f", &val)
-				return val
+				if s, ok := r.Value[1].(string); ok {
+					fmt.Sscanf(s, "%f", &val)
+				}
+				out[r.Metric["function"]] = val
 			}
-			return 0
+			return out, nil
+		}
+		total := func(q string) float64 {
+			values, _ := query(q)
+			return values[""]
+		}
+
+		invocations, err := query("sum by (function) (http_requests_total)")
+		if err != nil {
+			fmt.Printf("Error querying Prometheus at %s: %v\n", promURL, err)
+			return
 		}
+		errors, _ := query(`sum by (function) (http_requests_total{status="5xx"})`)
+		coldStarts, _ := query("sum by (function) (function_cold_starts_total)")
+		p95, _ := query("histogram_quantile(0.95, sum by (function, le) (rate(function_invocation_duration_seconds_bucket[5m])))")
+		active, _ := query("sum by (function) (function_active_containers)")
+
+		totalReqs := total("sum(http_requests_total)")
+		totalErrors := total(`sum(http_requests_total{status="5xx"})`)
+		totalCold := total("sum(function_cold_starts_total)")
 
-		totalReqs := query("sum(http_requests_total)")
-		// Heuristic for cold starts: We don't have a direct metric yet, 
-		// but we can query total invocations. 
-		// In a real system, Gateway would emit 'function_cold_starts_total'
-		
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
 		fmt.Fprintln(w, "METRIC\tVALUE")
 		fmt.Fprintln(w, "------\t-----")
 		fmt.Fprintf(w, "Total Requests\t%.0f\n", totalReqs)
-		
+		fmt.Fprintf(w, "Error Rate\t%s\n", percent(totalErrors, totalReqs))
+		fmt.Fprintf(w, "Cold Starts\t%.0f (%s)\n", totalCold, percent(totalCold, totalReqs))
+		fmt.Fprintf(w, "Active Containers\t%.0f\n", total("sum(function_active_containers)"))
+		fmt.Fprintf(w, "Evictions\t%.0f\n", total("sum(reaper_evictions_total)"))
+
 		// Per function stats
-		// query: sum by (function) (http_requests_total)
-		fmt.Fprintln(w, "\nFUNCTION\tINVOCATIONS")
-		fmt.Fprintln(w, "--------\t-----------")
-		
-		// Harder to parse generic list in simple go helper, let's keep it simple
-		// We can list known functions? No, let's just query list.
-		
-		// For demo, just showing total is enough or we implement proper query
-		// Let's rely on the Web Dashboard for detailed charts.
-		
+		fmt.Fprintln(w, "\nFUNCTION\tINVOCATIONS\tERRORS\tCOLD STARTS\tP95 (5m)\tCONTAINERS")
+		fmt.Fprintln(w, "--------\t-----------\t------\t-----------\t--------\t----------")
+
+		names := make([]string, 0, len(invocations))
+		for name := range invocations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			latency := "-"
+			if v, ok := p95[name]; ok && v == v { // NaN when there was no traffic in the window
+				latency = fmt.Sprintf("%.0fms", v*1000)
+			}
+			fmt.Fprintf(w, "%s\t%.0f\t%s\t%.0f\t%s\t%.0f\n", name, invocations[name], percent(errors[name], invocations[name]), coldStarts[name], latency, active[name])
+		}
+
 		w.Flush()
 		fmt.Println("\nRun 'nanolambda dashboard' for detailed visualization.")
 	},
 }
 
+// percent formats part/whole as a percentage
+func percent(part, whole float64) string {
+	if whole == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", part/whole*100)
+}
+
 func init() {
+	metricsCmd.Flags().String("prometheus", "http://localhost:9090", "Prometheus URL")
 	rootCmd.AddCommand(metricsCmd)
 }
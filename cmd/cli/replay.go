@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/history"
+	"github.com/nikhi/nanolambda/pkg/logging"
+	"github.com/nikhi/nanolambda/pkg/registry"
+	"github.com/spf13/cobra"
+)
+
+// replayResult is the outcome of re-sending one recorded invocation
+type replayResult struct {
+	original  registry.Invocation
+	requestID string
+	status    int
+	body      []byte
+	duration  time.Duration
+	err       error
+}
+
+// verdict compares a replayed response to the recorded one
+func (r replayResult) verdict() string {
+	switch {
+	case r.err != nil:
+		return "error"
+	case r.original.Status >= 500 && r.status < 500:
+		return "fixed"
+	case r.original.Status < 500 && r.status >= 500:
+		return "regressed"
+	case r.original.Status != r.status:
+		return "status changed"
+	case r.original.ResponseBody == nil:
+		return "same status"
+	case sameResponse(r.original.ResponseBody, r.body, r.original.ResponseTruncated):
+		return "same"
+	default:
+		return "response changed"
+	}
+}
+
+// sameResponse compares bodies as json when both parse, byte for byte otherwise
+// a truncated recording only has to match the start of the replayed body
+func sameResponse(recorded, replayed []byte, truncated bool) bool {
+	if truncated {
+		return bytes.HasPrefix(replayed, recorded)
+	}
+	var a, b interface{}
+	if json.Unmarshal(recorded, &a) == nil && json.Unmarshal(replayed, &b) == nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return bytes.Equal(recorded, replayed)
+}
+
+// send re-sends a recorded request body to the gateway
+func send(target string, inv registry.Invocation) replayResult {
+	res := replayResult{original: inv, requestID: "replay-" + logging.NewRequestID()[:16]}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(inv.RequestBody))
+	if err != nil {
+		res.err = err
+		return res
+	}
+	if json.Valid(inv.RequestBody) {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(logging.RequestIDHeader, res.requestID)
+
+	started := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	defer resp.Body.Close()
+	res.body, res.err = io.ReadAll(resp.Body)
+	res.duration = time.Since(started)
+	res.status = resp.StatusCode
+	return res
+}
+
+// clip shortens a body for the comparison output
+func clip(body []byte, n int) string {
+	s := strings.TrimSpace(string(body))
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay [function]",
+	Short: "Re-send recorded invocations and compare the responses",
+	Long: `re-sends the request bodies kept in the invocation history, e.g. every failure
+of the last hour, to the current or a named version and compares status and response.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("id")
+		version, _ := cmd.Flags().GetInt("version")
+		speed, _ := cmd.Flags().GetFloat64("speed")
+		if (id == "") == (len(args) == 0) {
+			fmt.Println("Give either a function (with filters) or --id")
+			return
+		}
+		if speed < 0 {
+			fmt.Println("--speed can't be negative")
+			return
+		}
+
+		reg, err := registry.NewManager("./data/nanolambda.db")
+		if err != nil {
+			fmt.Printf("Error connecting to registry: %v\n", err)
+			return
+		}
+		defer reg.Close()
+
+		var invocations []registry.Invocation
+		if id != "" {
+			inv, err := reg.GetInvocation(id)
+			if err != nil {
+				fmt.Printf("Invocation %s not found: %v\n", id, err)
+				return
+			}
+			invocations = append(invocations, *inv)
+		} else {
+			filter, err := invocationFilter(cmd, args[0])
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				return
+			}
+			if invocations, err = reg.ListInvocations(filter); err != nil {
+				fmt.Printf("Error reading invocation history: %v\n", err)
+				return
+			}
+		}
+
+		// Only invocations whose full request body was kept can be replayed, oldest first
+		var replayable []registry.Invocation
+		skipped := 0
+		for i := len(invocations) - 1; i >= 0; i-- {
+			inv := invocations[i]
+			if inv.RequestBody == nil || inv.RequestTruncated {
+				skipped++
+				continue
+			}
+			replayable = append(replayable, inv)
+		}
+		if skipped > 0 {
+			fmt.Printf("Skipping %d invocation(s) without a complete recorded request body\n", skipped)
+		}
+		if len(replayable) == 0 {
+			fmt.Println("Nothing to replay.")
+			return
+		}
+
+		function := replayable[0].Function
+		targetName := function
+		if version > 0 {
+			targetName = fmt.Sprintf("%s@%d", function, version)
+		}
+		target := fmt.Sprintf("%s/function/%s", strings.TrimRight(gatewayURL, "/"), url.PathEscape(targetName))
+		fmt.Printf("Replaying %d invocation(s) against %s\n\n", len(replayable), targetName)
+
+		// Keep the recorded gaps between requests, divided by speed; 0 sends them one after another
+		results := make([]replayResult, len(replayable))
+		var wg sync.WaitGroup
+		begin := time.Now()
+		for i, inv := range replayable {
+			if speed == 0 {
+				results[i] = send(target, inv)
+				continue
+			}
+			offset := time.Duration(float64(inv.StartedAt.Sub(replayable[0].StartedAt)) / speed)
+			time.Sleep(time.Until(begin.Add(offset)))
+			wg.Add(1)
+			go func(i int, inv registry.Invocation) {
+				defer wg.Done()
+				results[i] = send(target, inv)
+			}(i, inv)
+		}
+		wg.Wait()
+
+		counts := make(map[string]int)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATUS\tNEW STATUS\tDURATION\tNEW DURATION\tRESULT")
+		for _, r := range results {
+			v := r.verdict()
+			counts[v]++
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n", r.original.ID, r.original.Status, r.status,
+				r.original.Duration.Round(time.Millisecond), r.duration.Round(time.Millisecond), v)
+		}
+		w.Flush()
+
+		// Side by side responses for everything that didn't replay the same
+		for _, r := range results {
+			v := r.verdict()
+			if v == "same" || v == "same status" {
+				continue
+			}
+			fmt.Printf("\n%s (%s, replayed as %s)\n", r.original.ID, v, r.requestID)
+			if strings.Contains(string(r.original.RequestBody), history.Redacted) {
+				fmt.Println("  note: the recorded request had redacted fields")
+			}
+			if r.err != nil {
+				fmt.Printf("  error:    %v\n", r.err)
+				continue
+			}
+			recorded := "(not kept)"
+			if r.original.ResponseBody != nil {
+				recorded = clip(r.original.ResponseBody, 200)
+			}
+			fmt.Printf("  recorded: %d %s\n", r.original.Status, recorded)
+			fmt.Printf("  replayed: %d %s\n", r.status, clip(r.body, 200))
+		}
+
+		var summary []string
+		for _, v := range []string{"same", "same status", "fixed", "regressed", "status changed", "response changed", "error"} {
+			if counts[v] > 0 {
+				summary = append(summary, fmt.Sprintf("%d %s", counts[v], v))
+			}
+		}
+		fmt.Printf("\n%d replayed: %s\n", len(results), strings.Join(summary, ", "))
+	},
+}
+
+func init() {
+	addInvocationFilterFlags(replayCmd, 0)
+	replayCmd.Flags().String("id", "", "Replay a single invocation by id")
+	replayCmd.Flags().Int("version", 0, "Send to this version instead of the current one")
+	replayCmd.Flags().Float64("speed", 1, "Timing relative to the recording (1 original, 10 ten times faster, 0 one after another)")
+	rootCmd.AddCommand(replayCmd)
+}
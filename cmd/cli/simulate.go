@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/logging"
+	"github.com/nikhi/nanolambda/pkg/simulate"
+	"github.com/spf13/cobra"
+)
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate [trace.jsonl]",
+	Short: "Replay an invocation trace offline against keep-alive and pre-warm policies",
+	Long: `runs the reaper on a virtual clock with fake containers for every policy and reports
+cold starts, added latency and container-seconds. the trace has one json invocation per
+line with function, started_at and duration_ms, e.g. from 'nanolambda history --json'.
+use - to read it from stdin.
+
+policies are parts joined with '+': fixed[:keepalive], hybrid[:keepalive], pause:duration
+and predictor:threshold, e.g. --policy fixed:60s --policy hybrid+pause:2s --policy fixed+predictor:5`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		specs, _ := cmd.Flags().GetStringArray("policy")
+		config := simulate.DefaultConfig()
+		config.ColdStart, _ = cmd.Flags().GetDuration("cold-start")
+		config.Resume, _ = cmd.Flags().GetDuration("resume")
+		config.WarmupTTL, _ = cmd.Flags().GetDuration("warmup-ttl")
+
+		var policies []simulate.Policy
+		for _, spec := range specs {
+			p, err := simulate.ParsePolicy(spec)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				return
+			}
+			policies = append(policies, p)
+		}
+
+		var in io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				fmt.Printf("Error opening trace: %v\n", err)
+				return
+			}
+			defer f.Close()
+			in = f
+		}
+		events, err := simulate.ReadTrace(in)
+		if err != nil {
+			fmt.Printf("Error reading trace: %v\n", err)
+			return
+		}
+		if len(events) == 0 {
+			fmt.Println("The trace has no invocations.")
+			return
+		}
+
+		functions := make(map[string]bool)
+		for _, ev := range events {
+			functions[ev.Function] = true
+		}
+		span := events[len(events)-1].StartedAt.Sub(events[0].StartedAt)
+		fmt.Printf("Simulating %d invocation(s) of %d function(s) over %s, cold start %s\n\n",
+			len(events), len(functions), span.Round(time.Second), config.ColdStart)
+
+		// the reaper logs every container it starts and stops
+		logging.Setup(os.Stderr, "error")
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "POLICY\tCOLD STARTS\tCOLD RATE\tRESUMES\tP99 ADDED\tCONTAINER-SECONDS\tWARMUPS (HIT/WASTED)")
+		for _, p := range policies {
+			r := simulate.Run(events, p, config)
+			warmups := "-"
+			if p.Predictor {
+				warmups = fmt.Sprintf("%d (%d/%d)", r.Warmups, r.WarmupHits, r.WarmupWasted)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%.2f%%\t%d\t%s\t%.0f\t%s\n", r.Policy, r.ColdStarts, r.ColdStartRate()*100,
+				r.Resumes, r.P99AddedLatency, r.ContainerSeconds, warmups)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	simulateCmd.Flags().StringArray("policy", []string{"fixed:10s", "fixed:60s", "hybrid"}, "Policy to evaluate, repeat to compare several")
+	simulateCmd.Flags().Duration("cold-start", 500*time.Millisecond, "Latency a cold start adds to its request")
+	simulateCmd.Flags().Duration("resume", 10*time.Millisecond, "Latency resuming a paused container adds")
+	simulateCmd.Flags().Duration("warmup-ttl", 300*time.Second, "How long predictor warmups are kept without traffic")
+	rootCmd.AddCommand(simulateCmd)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/registry"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// priceSheet is what usage is billed at, in dollars
+type priceSheet struct {
+	PerMillionInvocations float64 `yaml:"per_million_invocations"`
+	PerGBSecond           float64 `yaml:"per_gb_second"`
+	PerIdleGBSecond       float64 `yaml:"per_idle_gb_second"`
+}
+
+// defaultPrices follows typical public cloud on-demand pricing, idle time at a quarter of the active rate
+var defaultPrices = priceSheet{
+	PerMillionInvocations: 0.20,
+	PerGBSecond:           0.0000166667,
+	PerIdleGBSecond:       0.0000041667,
+}
+
+// cost estimates the bill for a usage total
+func (p priceSheet) cost(t registry.UsageTotal) float64 {
+	return float64(t.Invocations)/1e6*p.PerMillionInvocations + t.GBSeconds*p.PerGBSecond + t.IdleGBSeconds*p.PerIdleGBSecond
+}
+
+// loadPrices reads a price sheet, prices missing from the file keep their defaults
+func loadPrices(path string) (priceSheet, error) {
+	prices := defaultPrices
+	if path == "" {
+		return prices, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return prices, fmt.Errorf("error reading price sheet: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &prices); err != nil {
+		return prices, fmt.Errorf("error parsing price sheet: %w", err)
+	}
+	return prices, nil
+}
+
+// parseTime accepts a date (2006-01-02, local midnight) or an RFC3339 timestamp
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show metered GB-seconds and estimated cost per function or namespace",
+	Run: func(cmd *cobra.Command, args []string) {
+		fromFlag, _ := cmd.Flags().GetString("from")
+		toFlag, _ := cmd.Flags().GetString("to")
+		by, _ := cmd.Flags().GetString("by")
+		pricesFlag, _ := cmd.Flags().GetString("prices")
+
+		// Default to the current month so far
+		now := time.Now()
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		to := now
+		var err error
+		if fromFlag != "" {
+			if from, err = parseTime(fromFlag); err != nil {
+				fmt.Printf("Invalid --from %q: want YYYY-MM-DD or RFC3339\n", fromFlag)
+				return
+			}
+		}
+		if toFlag != "" {
+			if to, err = parseTime(toFlag); err != nil {
+				fmt.Printf("Invalid --to %q: want YYYY-MM-DD or RFC3339\n", toFlag)
+				return
+			}
+		}
+		if !to.After(from) {
+			fmt.Println("--to must be after --from")
+			return
+		}
+
+		prices, err := loadPrices(pricesFlag)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+
+		reg, err := registry.NewManager("./data/nanolambda.db")
+		if err != nil {
+			fmt.Printf("Error connecting to registry: %v\n", err)
+			return
+		}
+		defer reg.Close()
+
+		// Usage is rolled up in utc hours, so partial hours at either end are counted whole
+		totals, err := reg.QueryUsage(from.UTC().Truncate(time.Hour), to, by)
+		if err != nil {
+			fmt.Printf("Error querying usage: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Usage from %s to %s\n\n", from.Format(time.RFC3339), to.Format(time.RFC3339))
+		if len(totals) == 0 {
+			fmt.Println("No usage recorded in this period.")
+			return
+		}
+
+		var sum registry.UsageTotal
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintf(w, "%s\tINVOCATIONS\tGB-SECONDS\tIDLE GB-SECONDS\tEST. COST\n", map[string]string{registry.ByFunction: "FUNCTION", registry.ByNamespace: "NAMESPACE"}[by])
+		for _, t := range totals {
+			fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f\t$%.4f\n", t.Key, t.Invocations, t.GBSeconds, t.IdleGBSeconds, prices.cost(t))
+			sum.Invocations += t.Invocations
+			sum.GBSeconds += t.GBSeconds
+			sum.IdleGBSeconds += t.IdleGBSeconds
+		}
+		fmt.Fprintf(w, "TOTAL\t%d\t%.2f\t%.2f\t$%.4f\n", sum.Invocations, sum.GBSeconds, sum.IdleGBSeconds, prices.cost(sum))
+		w.Flush()
+	},
+}
+
+func init() {
+	usageCmd.Flags().String("from", "", "Start of the period, YYYY-MM-DD or RFC3339 (default: start of this month)")
+	usageCmd.Flags().String("to", "", "End of the period, YYYY-MM-DD or RFC3339 (default: now)")
+	usageCmd.Flags().String("by", registry.ByFunction, "Group by function or namespace")
+	usageCmd.Flags().String("prices", "", "YAML price sheet (per_million_invocations, per_gb_second, per_idle_gb_second)")
+	rootCmd.AddCommand(usageCmd)
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/deploy"
+	"github.com/nikhi/nanolambda/pkg/docker"
+	"github.com/nikhi/nanolambda/pkg/events"
+	"github.com/nikhi/nanolambda/pkg/reaper"
+	"github.com/nikhi/nanolambda/pkg/registry"
+	"github.com/nikhi/nanolambda/pkg/runner"
+	"github.com/nikhi/nanolambda/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startError is a cold start failure with the HTTP status to report
+type startError struct {
+	status int
+	msg    string
+}
+
+func (e *startError) Error() string { return e.msg }
+
+// parseTarget splits "name@version" into its parts
+// a plain name targets the latest version (0)
+func parseTarget(key string) (string, int, error) {
+	name, v, pinned := strings.Cut(key, "@")
+	if !pinned {
+		return name, 0, nil
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil || version < 1 {
+		return "", 0, fmt.Errorf("invalid version %q", v)
+	}
+	return name, version, nil
+}
+
+// targetKey is the reaper key for a function, pinned to a version if one is given
+func targetKey(name string, version int) string {
+	if version == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s@%d", name, version)
+}
+
+// resolve looks up the function (or pinned version) a reaper key refers to
+func (app *App) resolve(key string) (*registry.Function, error) {
+	name, version, err := parseTarget(key)
+	if err != nil {
+		return nil, err
+	}
+	return app.Registry.GetFunctionVersion(name, version)
+}
+
+// coldStartTiming is the per-phase breakdown of a cold start, in order
+type coldStartTiming []coldStartPhase
+
+type coldStartPhase struct {
+	name     string
+	duration time.Duration
+}
+
+func (t *coldStartTiming) add(name string, d time.Duration) {
+	*t = append(*t, coldStartPhase{name, d})
+}
+
+// observe records each phase in the cold start phase histogram
+func (t coldStartTiming) observe(function string) {
+	for _, p := range t {
+		coldStartPhaseDuration.WithLabelValues(function, p.name).Observe(p.duration.Seconds())
+	}
+}
+
+// serverTiming formats the phases as a Server-Timing header value
+func (t coldStartTiming) serverTiming() string {
+	parts := make([]string, 0, len(t))
+	for _, p := range t {
+		parts = append(parts, fmt.Sprintf("%s;dur=%.1f", p.name, float64(p.duration.Microseconds())/1000))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// probeFor returns the readiness probe for fn, its own settings over the defaults
+func probeFor(fn *registry.Function) runner.Probe {
+	p := runner.DefaultProbe()
+	c := fn.Readiness
+	if c == nil {
+		return p
+	}
+	seconds := func(v float64) time.Duration { return time.Duration(v * float64(time.Second)) }
+	if c.Path != "" {
+		p.Path = c.Path
+	}
+	if c.InitialDelay > 0 {
+		p.InitialDelay = seconds(c.InitialDelay)
+	}
+	if c.Period > 0 {
+		p.Period = seconds(c.Period)
+	}
+	if c.Timeout > 0 {
+		p.Timeout = seconds(c.Timeout)
+	}
+	if c.Backoff > 0 {
+		p.Backoff = c.Backoff
+	}
+	if c.MaxPeriod > 0 {
+		p.MaxPeriod = seconds(c.MaxPeriod)
+	}
+	if c.Deadline > 0 {
+		p.Deadline = seconds(c.Deadline)
+	}
+	if p.MaxPeriod < p.Period {
+		p.MaxPeriod = p.Period
+	}
+	return p
+}
+
+// startReplica starts a container for fn, waits until it passes the readiness probe
+// and registers it under key with the given idle timeout
+// origin records why it was started (see the reaper origin constants)
+func (app *App) startReplica(ctx context.Context, key string, fn *registry.Function, timeoutSeconds int, origin string) (_ string, timing coldStartTiming, serr *startError) {
+	ctx, span := tracer.Start(ctx, "cold start", trace.WithAttributes(tracing.FunctionKey.String(key), attribute.String("origin", origin)))
+	defer span.End()
+
+	defer func() { timing.observe(key) }()
+
+	// Lifecycle events for the dashboard and `nanolambda events`
+	begin := time.Now()
+	app.Events.Publish(events.Event{Type: events.ColdStartBegin, Function: key, Version: fn.Version, Origin: origin})
+	defer func() {
+		end := events.Event{Type: events.ColdStartEnd, Function: key, Version: fn.Version, Origin: origin,
+			DurationMS: float64(time.Since(begin)) / float64(time.Millisecond)}
+		if serr != nil {
+			end.Error = serr.msg
+		}
+		app.Events.Publish(end)
+	}()
+
+	// Make room on the host, evicting idle containers if needed
+	release, err := app.Reaper.Reserve(ctx, fn.MemoryLimit)
+	if err != nil {
+		return "", nil, &startError{http.StatusServiceUnavailable, fmt.Sprintf("Cannot start %s: %v", key, err)}
+	}
+	defer release()
+
+	// Start Container
+	startCtx, startSpan := tracer.Start(ctx, "container.start")
+	addr, id, phases, err := app.startContainer(startCtx, fn)
+	tracing.End(startSpan, err)
+	if phases.Pull > 0 {
+		timing.add("pull", phases.Pull)
+	}
+	timing.add("create", phases.Create)
+	timing.add("start", phases.Start)
+	if err != nil {
+		return "", timing, &startError{http.StatusInternalServerError, fmt.Sprintf("Failed to start container: %v", err)}
+	}
+
+	// Wait for Container to be Ready
+	readyStart := time.Now()
+	_, readySpan := tracer.Start(ctx, "readiness")
+	err = probeFor(fn).Wait(ctx, addr)
+	tracing.End(readySpan, err)
+	if err != nil {
+		// Clean up if it failed to start properly
+		app.Docker.StopContainer(context.Background(), id)
+		return "", timing, &startError{http.StatusGatewayTimeout, fmt.Sprintf("Container timed out starting: %v", err)}
+	}
+	timing.add("ready", time.Since(readyStart))
+
+	// Register with Reaper
+	app.Reaper.Register(ctx, key, reaper.ContainerInfo{
+		ID:          id,
+		Address:     addr,
+		Timeout:     time.Duration(timeoutSeconds) * time.Second,
+		MemoryLimit: fn.MemoryLimit,
+		Origin:      origin,
+		Version:     fn.Version,
+		MaxLifetime: time.Duration(fn.MaxLifetime) * time.Second,
+		MaxRequests: fn.MaxRequests,
+	})
+	return addr, timing, nil
+}
+
+// launch starts a container for a function on behalf of the reaper
+// (policy pre-warms and replicas kept for the function's minimum instances)
+// starts count towards the function's circuit breaker like request-driven ones,
+// and nothing is started while it is open except the breaker's own probes
+func (app *App) launch(ctx context.Context, key, origin string) error {
+	probe := origin == reaper.OriginProbe
+	if !probe {
+		if err := app.Breakers.Allow(key); err != nil {
+			return err
+		}
+	}
+	fn, err := app.resolve(key)
+	if err != nil {
+		return err
+	}
+	if _, _, ok := app.claimPooled(ctx, key, fn, fn.Timeout, origin); ok {
+		return nil
+	}
+	if _, _, serr := app.startReplica(ctx, key, fn, fn.Timeout, origin); serr != nil {
+		err := errors.New(serr.msg)
+		// the breaker records its probes itself, and a full host isn't the function's fault
+		if !probe && serr.status != http.StatusServiceUnavailable {
+			app.Breakers.StartFailed(ctx, key, err)
+		}
+		return err
+	}
+	if !probe {
+		app.Breakers.StartSucceeded(key)
+	}
+	return nil
+}
+
+// claimPooled binds a generic runner from the warm pool to a buildless function
+// returns false if the pool is empty or the function needs its own image
+func (app *App) claimPooled(ctx context.Context, key string, fn *registry.Function, timeoutSeconds int, origin string) (string, coldStartTiming, bool) {
+	if fn.Artifact == "" || fn.ImageTag != deploy.BaseImage {
+		return "", nil, false
+	}
+	info, ok := app.Reaper.Claim()
+	if !ok {
+		return "", nil, false
+	}
+
+	var timing coldStartTiming
+	loadStart := time.Now()
+	_, span := tracer.Start(ctx, "pool.load", trace.WithAttributes(tracing.FunctionKey.String(key), attribute.String("origin", origin)))
+	// pooled runners start unlimited, they get the function's memory limit before its code
+	err := app.Docker.SetMemoryLimit(ctx, info.ID, fn.MemoryLimit)
+	if err == nil {
+		var code io.ReadCloser
+		if code, err = app.Artifacts.Open(fn.Artifact); err == nil {
+			err = runner.Load(ctx, info.Address, code)
+			code.Close()
+		}
+	}
+	tracing.End(span, err)
+	if err != nil {
+		app.Logger.WarnContext(ctx, "pooled runner failed to load function, falling back to cold start",
+			"function", fn.Name, "container", info.ID[:12], "error", err)
+		app.Docker.StopContainer(context.Background(), info.ID)
+		return "", nil, false
+	}
+	timing.add("load", time.Since(loadStart))
+	timing.observe(key)
+
+	// rename so `nanolambda logs` finds it under the function's name
+	if err := app.Docker.RenameContainer(context.Background(), info.ID, docker.ContainerName(fn.Name)); err != nil {
+		app.Logger.WarnContext(ctx, "failed to rename pooled container", "function", fn.Name, "container", info.ID[:12], "error", err)
+	}
+	app.Reaper.Register(ctx, key, reaper.ContainerInfo{
+		ID:          info.ID,
+		Address:     info.Address,
+		Timeout:     time.Duration(timeoutSeconds) * time.Second,
+		MemoryLimit: fn.MemoryLimit,
+		Origin:      origin,
+		FromPool:    true,
+		Version:     fn.Version,
+		MaxLifetime: time.Duration(fn.MaxLifetime) * time.Second,
+		MaxRequests: fn.MaxRequests,
+	})
+	return info.Address, timing, true
+}
+
+// startContainer starts a container for fn
+// buildless functions get their code artifact copied onto the base image
+func (app *App) startContainer(ctx context.Context, fn *registry.Function) (string, string, docker.StartPhases, error) {
+	spec := docker.ContainerSpec{Image: fn.Image(), Name: fn.Name, MemoryMB: fn.MemoryLimit}
+	if fn.Artifact != "" {
+		code, err := app.Artifacts.Open(fn.Artifact)
+		if err != nil {
+			return "", "", docker.StartPhases{}, err
+		}
+		defer code.Close()
+		spec.Code = code
+	}
+	return app.Docker.StartContainerTimed(ctx, spec)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/registry"
+	"github.com/nikhi/nanolambda/web"
+)
+
+// liveWindow is how far back the dashboard's live numbers go
+const liveWindow = 5 * time.Minute
+
+const windowSeconds = int(liveWindow / time.Second)
+
+// liveStep is the width of one point of the dashboard's traffic series
+const liveStep = 10 * time.Second
+
+// liveLatencies is how many recent invocation latencies are kept for percentiles
+const liveLatencies = 4096
+
+// liveStats aggregates recent invocations in-process, so the dashboard works without Prometheus
+type liveStats struct {
+	mu        sync.Mutex
+	total     int64                             // invocations since the gateway started
+	seconds   map[string]*[windowSeconds]second // per function, indexed by unix second modulo the window
+	latencies [liveLatencies]latency
+	next      int
+}
+
+type second struct {
+	unix       int64
+	requests   int
+	errors     int
+	coldStarts int
+}
+
+type latency struct {
+	at       time.Time
+	function string
+	duration time.Duration
+}
+
+func newLiveStats() *liveStats {
+	return &liveStats{seconds: make(map[string]*[windowSeconds]second)}
+}
+
+// record counts one finished invocation
+func (s *liveStats) record(function string, status int, cold bool, duration time.Duration) {
+	now := time.Now()
+	unix := now.Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total++
+	ring, ok := s.seconds[function]
+	if !ok {
+		ring = new([windowSeconds]second)
+		s.seconds[function] = ring
+	}
+	sec := &ring[unix%int64(windowSeconds)]
+	if sec.unix != unix {
+		*sec = second{unix: unix}
+	}
+	sec.requests++
+	if status >= 500 {
+		sec.errors++
+	}
+	if cold {
+		sec.coldStarts++
+	}
+
+	s.latencies[s.next] = latency{at: now, function: function, duration: duration}
+	s.next = (s.next + 1) % liveLatencies
+}
+
+// TrafficPoint is one step of the dashboard's traffic series
+type TrafficPoint struct {
+	Time       time.Time `json:"time"`
+	Requests   int       `json:"requests"`
+	Errors     int       `json:"errors"`
+	ColdStarts int       `json:"cold_starts"`
+}
+
+// FunctionTraffic is a function's traffic over the live window
+type FunctionTraffic struct {
+	Function   string  `json:"function"`
+	Requests   int     `json:"requests"`
+	Errors     int     `json:"errors"`
+	ColdStarts int     `json:"cold_starts"`
+	P50MS      float64 `json:"p50_ms"`
+	P99MS      float64 `json:"p99_ms"`
+	Replicas   int     `json:"replicas"`
+}
+
+// MetricsSummary is the response of GET /admin/metrics/summary
+type MetricsSummary struct {
+	WindowSeconds    int               `json:"window_seconds"`
+	TotalRequests    int64             `json:"total_requests"` // since the gateway started
+	Requests         int               `json:"requests"`
+	Errors           int               `json:"errors"`
+	ColdStarts       int               `json:"cold_starts"`
+	P50MS            float64           `json:"p50_ms"`
+	P99MS            float64           `json:"p99_ms"`
+	ActiveContainers int               `json:"active_containers"`
+	PooledContainers int               `json:"pooled_containers"`
+	WarmupHits       int               `json:"warmup_hits"`
+	WarmupWasted     int               `json:"warmup_wasted"`
+	Series           []TrafficPoint    `json:"series"` // liveStep apart, oldest first
+	Functions        []FunctionTraffic `json:"functions"`
+}
+
+// summary totals the live window, overall and per function
+func (s *liveStats) summary(now time.Time) MetricsSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := MetricsSummary{WindowSeconds: windowSeconds, TotalRequests: s.total, Functions: []FunctionTraffic{}}
+	oldest := now.Add(-liveWindow)
+	first := oldest.Truncate(liveStep).Add(liveStep)
+	out.Series = make([]TrafficPoint, int(now.Sub(first)/liveStep)+1)
+	for i := range out.Series {
+		out.Series[i].Time = first.Add(time.Duration(i) * liveStep)
+	}
+
+	for function, ring := range s.seconds {
+		f := FunctionTraffic{Function: function}
+		for _, sec := range ring {
+			if sec.unix <= oldest.Unix() || sec.unix > now.Unix() {
+				continue
+			}
+			f.Requests += sec.requests
+			f.Errors += sec.errors
+			f.ColdStarts += sec.coldStarts
+			if i := int(time.Unix(sec.unix, 0).Sub(first) / liveStep); i >= 0 && i < len(out.Series) {
+				out.Series[i].Requests += sec.requests
+				out.Series[i].Errors += sec.errors
+				out.Series[i].ColdStarts += sec.coldStarts
+			}
+		}
+		if f.Requests == 0 {
+			continue
+		}
+		out.Requests += f.Requests
+		out.Errors += f.Errors
+		out.ColdStarts += f.ColdStarts
+		f.P50MS, f.P99MS = s.percentiles(oldest, function)
+		out.Functions = append(out.Functions, f)
+	}
+	sort.Slice(out.Functions, func(i, j int) bool { return out.Functions[i].Requests > out.Functions[j].Requests })
+	out.P50MS, out.P99MS = s.percentiles(oldest, "")
+	return out
+}
+
+// percentiles returns the median and 99th percentile latency in ms of the kept invocations
+// after since, of one function or of all of them
+// callers must hold s.mu
+func (s *liveStats) percentiles(since time.Time, function string) (float64, float64) {
+	var durations []time.Duration
+	for _, l := range s.latencies {
+		if l.at.After(since) && (function == "" || l.function == function) {
+			durations = append(durations, l.duration)
+		}
+	}
+	if len(durations) == 0 {
+		return 0, 0
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	at := func(p float64) float64 {
+		d := durations[int(p*float64(len(durations)-1))]
+		return float64(d.Microseconds()) / 1000
+	}
+	return at(0.50), at(0.99)
+}
+
+// MetricsSummaryHandler reports traffic, latency and containers over the last few minutes
+func (app *App) MetricsSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	summary := app.Live.summary(time.Now())
+	for i, f := range summary.Functions {
+		summary.Functions[i].Replicas = app.Reaper.Replicas(f.Function)
+	}
+	for _, replicas := range app.Reaper.AllContainers() {
+		summary.ActiveContainers += len(replicas)
+	}
+	summary.PooledContainers, _ = app.Reaper.PoolStats()
+	for _, stats := range app.Reaper.WarmupStats() {
+		summary.WarmupHits += stats.Hits
+		summary.WarmupWasted += stats.Wasted
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// FunctionsHandler lists every function with its replicas and circuit breaker
+func (app *App) FunctionsHandler(w http.ResponseWriter, r *http.Request) {
+	functions, err := app.Registry.ListFunctions()
+	if err != nil {
+		http.Error(w, "Failed to list functions", http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]FunctionDescription, 0, len(functions))
+	for i := range functions {
+		out = append(out, app.describe(functions[i].Name, &functions[i]))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// ContainerDescription is one live container of GET /admin/containers
+type ContainerDescription struct {
+	Function string `json:"function"` // "name@version" for pinned versions
+	ReplicaDescription
+}
+
+// ContainersHandler lists the live containers of every function and the warm pool
+func (app *App) ContainersHandler(w http.ResponseWriter, r *http.Request) {
+	out := struct {
+		Containers []ContainerDescription `json:"containers"`
+		PoolIdle   int                    `json:"pool_idle"`
+		PoolSize   int                    `json:"pool_size"`
+	}{Containers: []ContainerDescription{}}
+
+	for key, replicas := range app.Reaper.AllContainers() {
+		for _, info := range replicas {
+			out.Containers = append(out.Containers, ContainerDescription{key, describeReplica(info)})
+		}
+	}
+	sort.Slice(out.Containers, func(i, j int) bool {
+		a, b := out.Containers[i], out.Containers[j]
+		if a.Function != b.Function {
+			return a.Function < b.Function
+		}
+		return a.StartedAt.Before(b.StartedAt)
+	})
+	out.PoolIdle, out.PoolSize = app.Reaper.PoolStats()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// InvocationDescription is one recorded invocation, without its bodies
+type InvocationDescription struct {
+	ID         string    `json:"id"`
+	RequestID  string    `json:"request_id"`
+	Function   string    `json:"function"`
+	Version    int       `json:"version,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS float64   `json:"duration_ms"`
+	Status     int       `json:"status"`
+	Start      string    `json:"start"`
+	Container  string    `json:"container,omitempty"`
+	HasPayload bool      `json:"has_payload"`
+}
+
+// InvocationsHandler lists recent invocations from the history, newest first
+// ?function=, ?request_id=, ?errors=true and ?limit= (default 50) narrow it down
+func (app *App) InvocationsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := registry.InvocationFilter{
+		Function:  query.Get("function"),
+		RequestID: query.Get("request_id"),
+		Errors:    query.Get("errors") == "true",
+		Limit:     50,
+	}
+	if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 {
+		filter.Limit = min(v, 1000)
+	}
+
+	invocations, err := app.Registry.ListInvocations(filter)
+	if err != nil {
+		http.Error(w, "Failed to read invocation history", http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]InvocationDescription, 0, len(invocations))
+	for _, inv := range invocations {
+		out = append(out, InvocationDescription{
+			ID:         inv.ID,
+			RequestID:  inv.RequestID,
+			Function:   inv.Function,
+			Version:    inv.Version,
+			StartedAt:  inv.StartedAt,
+			DurationMS: float64(inv.Duration.Microseconds()) / 1000,
+			Status:     inv.Status,
+			Start:      inv.Start,
+			Container:  inv.ContainerID[:min(12, len(inv.ContainerID))],
+			HasPayload: inv.HasPayload(),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// dashboardHandler serves the embedded dashboard under /admin/dashboard/
+func dashboardHandler() http.Handler {
+	files, err := fs.Sub(web.Dashboard, "dashboard")
+	if err != nil {
+		panic(err) // the embedded directory is fixed at build time
+	}
+	return http.StripPrefix("/admin/dashboard/", http.FileServer(http.FS(files)))
+}
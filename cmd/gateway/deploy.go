@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nikhi/nanolambda/pkg/deploy"
+	"github.com/nikhi/nanolambda/pkg/registry"
+)
+
+// MaxBundleSize limits the size of an uploaded function bundle
+const MaxBundleSize = 512 << 20
+
+// DeploymentResponse is the JSON view of a deployment
+type DeploymentResponse struct {
+	ID          string     `json:"id"`
+	Function    string     `json:"function"`
+	Status      string     `json:"status"`
+	ImageTag    string     `json:"image_tag"`
+	ImageDigest string     `json:"image_digest,omitempty"`
+	Artifact    string     `json:"artifact,omitempty"`
+	Version     int        `json:"version,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	StatusURL   string     `json:"status_url"`
+	LogsURL     string     `json:"logs_url"`
+}
+
+func newDeploymentResponse(d *registry.Deployment) DeploymentResponse {
+	return DeploymentResponse{
+		ID:          d.ID,
+		Function:    d.Function,
+		Status:      d.Status,
+		ImageTag:    d.ImageTag,
+		ImageDigest: d.ImageDigest,
+		Artifact:    d.Artifact,
+		Version:     d.Version,
+		Error:       d.Error,
+		CreatedAt:   d.CreatedAt,
+		FinishedAt:  d.FinishedAt,
+		StatusURL:   "/admin/deployments/" + d.ID,
+		LogsURL:     "/admin/deployments/" + d.ID + "/logs",
+	}
+}
+
+// DeployHandler accepts a tar.gz function bundle and builds it server-side
+func (app *App) DeployHandler(w http.ResponseWriter, r *http.Request) {
+	funcName := mux.Vars(r)["name"]
+
+	dir, err := os.MkdirTemp("", "nanolambda-bundle-")
+	if err != nil {
+		http.Error(w, "Failed to prepare bundle", http.StatusInternalServerError)
+		return
+	}
+
+	// 1. Unpack and validate the bundle
+	body := http.MaxBytesReader(w, r.Body, MaxBundleSize)
+	if err := deploy.ExtractBundle(body, dir); err != nil {
+		os.RemoveAll(dir)
+		http.Error(w, fmt.Sprintf("Invalid bundle: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	config, err := deploy.ValidateBundle(dir, funcName)
+	if err != nil {
+		os.RemoveAll(dir)
+		http.Error(w, fmt.Sprintf("Invalid bundle: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// 2. Build in the background, the caller polls or streams the deployment
+	dep, err := app.Deployer.Start(config, dir, func() { os.RemoveAll(dir) })
+	if err != nil {
+		os.RemoveAll(dir)
+		http.Error(w, fmt.Sprintf("Failed to start deployment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/admin/deployments/"+dep.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(newDeploymentResponse(dep))
+}
+
+// DeploymentHandler returns the current state of a deployment
+func (app *App) DeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	dep, ok := app.lookupDeployment(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDeploymentResponse(dep))
+}
+
+// DeploymentLogsHandler returns the build logs of a deployment
+// with ?follow=true the logs of an in-progress build are streamed until it finishes
+func (app *App) DeploymentLogsHandler(w http.ResponseWriter, r *http.Request) {
+	dep, ok := app.lookupDeployment(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	build, active := app.Deployer.Active(dep.ID)
+	if !active || r.URL.Query().Get("follow") != "true" {
+		if active {
+			logs, _ := build.Logs(0)
+			io.WriteString(w, logs)
+			return
+		}
+		io.WriteString(w, dep.Logs)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	offset := 0
+	for {
+		chunk, next := build.Logs(offset)
+		offset = next
+		if chunk != "" {
+			io.WriteString(w, chunk)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-build.Done():
+			// drain whatever was written after the last tick
+			chunk, _ := build.Logs(offset)
+			io.WriteString(w, chunk)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *App) lookupDeployment(w http.ResponseWriter, r *http.Request) (*registry.Deployment, bool) {
+	id := mux.Vars(r)["id"]
+	dep, err := app.Registry.GetDeployment(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, fmt.Sprintf("Deployment '%s' not found", id), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load deployment: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	return dep, true
+}
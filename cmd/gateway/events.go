@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/events"
+)
+
+// eventsHeartbeat keeps idle event streams from being closed by proxies
+const eventsHeartbeat = 15 * time.Second
+
+// EventsHandler streams lifecycle events as Server-Sent Events
+// the buffered recent events come first, only those after Last-Event-ID (or ?after=) when reconnecting
+// ?follow=false ends the stream after them; ?function= and ?type= (comma separated) filter
+func (app *App) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = query.Get("after")
+	}
+	after, _ := strconv.ParseUint(lastID, 10, 64)
+	function := query.Get("function")
+	types := make(map[string]bool)
+	for _, t := range strings.Split(query.Get("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+	match := func(e events.Event) bool {
+		if function != "" && e.Function != function && !strings.HasPrefix(e.Function, function+"@") {
+			return false
+		}
+		return len(types) == 0 || types[e.Type]
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	recent, stream, cancel := app.Events.Subscribe(after)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, e := range recent {
+		if match(e) {
+			writeEvent(w, e)
+		}
+	}
+	flusher.Flush()
+	if query.Get("follow") == "false" {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-stream:
+			if !match(e) {
+				continue
+			}
+			writeEvent(w, e)
+			flusher.Flush()
+		case <-heartbeat.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes one event in Server-Sent Events framing, the type as the event name
+func writeEvent(w io.Writer, e events.Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nikhi/nanolambda/pkg/breaker"
+	"github.com/nikhi/nanolambda/pkg/docker"
+	"github.com/nikhi/nanolambda/pkg/reaper"
+	"github.com/nikhi/nanolambda/pkg/registry"
+)
+
+// ReplicaDescription is one running container of a function
+type ReplicaDescription struct {
+	ID        string    `json:"id"`
+	State     string    `json:"state"`
+	Origin    string    `json:"origin"`
+	InFlight  int       `json:"in_flight"`
+	Served    int       `json:"served"`
+	StartedAt time.Time `json:"started_at"`
+	IdleFor   string    `json:"idle_for"`
+}
+
+// FunctionDescription is the response of GET /admin/functions/{name}
+type FunctionDescription struct {
+	Name         string               `json:"name"`
+	Version      int                  `json:"version"`
+	Runtime      string               `json:"runtime"`
+	Image        string               `json:"image"`
+	Artifact     string               `json:"artifact,omitempty"`
+	Timeout      int                  `json:"timeout"`
+	MemoryLimit  int64                `json:"memory_limit"`
+	MinInstances int                  `json:"min_instances"`
+	MaxInstances int                  `json:"max_instances,omitempty"`
+	DeployedAt   time.Time            `json:"deployed_at"`
+	Replicas     []ReplicaDescription `json:"replicas"`
+	Breaker      breaker.Status       `json:"breaker"`
+	Usage        *docker.Usage        `json:"usage,omitempty"` // nil until the stats collector has seen a container
+}
+
+// DescribeHandler reports a function's configuration, replicas and circuit breaker
+// "name@version" describes a pinned version
+func (app *App) DescribeHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["name"]
+	fn, err := app.resolve(key)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Function '%s' not found", key), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(app.describe(key, fn))
+}
+
+func (app *App) describe(key string, fn *registry.Function) FunctionDescription {
+	desc := FunctionDescription{
+		Name:         fn.Name,
+		Version:      fn.Version,
+		Runtime:      fn.Runtime,
+		Image:        fn.Image(),
+		Artifact:     fn.Artifact,
+		Timeout:      fn.Timeout,
+		MemoryLimit:  fn.MemoryLimit,
+		MinInstances: fn.MinInstancesAt(time.Now()),
+		MaxInstances: fn.MaxInstances,
+		DeployedAt:   fn.CreatedAt,
+		Replicas:     []ReplicaDescription{},
+		Breaker:      app.Breakers.Status(key),
+	}
+	if usage, ok := app.Stats.Usage()[key]; ok {
+		desc.Usage = &usage
+	}
+	for _, info := range app.Reaper.Containers(key) {
+		desc.Replicas = append(desc.Replicas, describeReplica(info))
+	}
+	return desc
+}
+
+// describeReplica summarizes a live container
+func describeReplica(info reaper.ContainerInfo) ReplicaDescription {
+	return ReplicaDescription{
+		ID:        info.ID[:12],
+		State:     info.State,
+		Origin:    info.Origin,
+		InFlight:  info.InFlight,
+		Served:    info.Served,
+		StartedAt: info.StartedAt,
+		IdleFor:   time.Since(info.LastAccessed).Round(time.Second).String(),
+	}
+}
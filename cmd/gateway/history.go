@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/history"
+)
+
+// historyConfig reads the invocation history settings from the environment:
+// HISTORY_SAMPLE_RATE (fraction of successful invocations whose bodies are kept),
+// HISTORY_MAX_BODY_BYTES, HISTORY_REDACT (comma separated json fields, "none" to disable)
+// and HISTORY_RETENTION (e.g. 72h, 0 keeps everything)
+func historyConfig() history.Config {
+	config := history.DefaultConfig()
+	if v, err := strconv.ParseFloat(os.Getenv("HISTORY_SAMPLE_RATE"), 64); err == nil {
+		config.SampleRate = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("HISTORY_MAX_BODY_BYTES")); err == nil && v >= 0 {
+		config.MaxBodyBytes = v
+	}
+	switch v := os.Getenv("HISTORY_REDACT"); v {
+	case "":
+	case "none":
+		config.Redact = nil
+	default:
+		config.Redact = strings.Split(v, ",")
+	}
+	if v, err := time.ParseDuration(os.Getenv("HISTORY_RETENTION")); err == nil {
+		config.Retention = v
+	}
+	return config
+}
@@ -3,51 +3,136 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/nikhi/nanolambda/pkg/breaker"
+	"github.com/nikhi/nanolambda/pkg/deploy"
 	"github.com/nikhi/nanolambda/pkg/docker"
+	"github.com/nikhi/nanolambda/pkg/events"
+	"github.com/nikhi/nanolambda/pkg/history"
+	"github.com/nikhi/nanolambda/pkg/logging"
+	"github.com/nikhi/nanolambda/pkg/metering"
+	"github.com/nikhi/nanolambda/pkg/predictor"
 	"github.com/nikhi/nanolambda/pkg/proxy"
 	"github.com/nikhi/nanolambda/pkg/reaper"
 	"github.com/nikhi/nanolambda/pkg/registry"
+	"github.com/nikhi/nanolambda/pkg/tracing"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
 	httpRequestsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
+			Help: "Total number of function invocations, by response status class (2xx, 4xx, 5xx)",
 		},
 		[]string{"function", "status"},
 	)
+	invocationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "function_invocation_duration_seconds",
+			Help:    "End-to-end invocation latency seen by the gateway, by start type (cold, warm)",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"function", "start"},
+	)
+	coldStartsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "function_cold_starts_total",
+			Help: "Invocations that had to start a container, by path (pool or regular)",
+		},
+		[]string{"function", "path"},
+	)
+	coldStartDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "function_cold_start_duration_seconds",
+			Help:    "Time from cold start to a ready container, by path (pool or regular)",
+			Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
+		},
+		[]string{"function", "path"},
+	)
+	coldStartPhaseDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "function_cold_start_phase_seconds",
+			Help:    "Cold start time by phase (pull, create, start, ready, load)",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+		},
+		[]string{"function", "phase"},
+	)
+	unknownFunctionRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "unknown_function_requests_total",
+			Help: "Invocations of functions that don't exist, kept out of the invocation history",
+		},
+	)
 )
 
+// tracer records invocation and cold start spans
+var tracer = otel.Tracer("github.com/nikhi/nanolambda/cmd/gateway")
+
 func init() {
 	prometheus.MustRegister(httpRequestsTotal)
+	prometheus.MustRegister(invocationDuration)
+	prometheus.MustRegister(coldStartsTotal)
+	prometheus.MustRegister(coldStartDuration)
+	prometheus.MustRegister(coldStartPhaseDuration)
+	prometheus.MustRegister(unknownFunctionRequests)
 }
 
 // App holds the application state
 type App struct {
-	Docker   *docker.Manager
-	Registry *registry.Manager
-	Reaper   *reaper.Manager
-	Router   *mux.Router
+	Docker     *docker.Manager
+	Registry   *registry.Manager
+	Reaper     *reaper.Manager
+	Autoscaler *reaper.Autoscaler
+	Deployer   *deploy.Deployer
+	Artifacts  *deploy.ArtifactStore
+	Predictor  *predictor.Predictor // nil unless PREDICTOR_ENABLED
+	Breakers   *breaker.Set
+	Stats      *docker.StatsCollector
+	Meter      *metering.Meter
+	History    *history.Recorder
+	Sampler    *tracing.Sampler
+	Events     *events.Bus
+	Live       *liveStats // recent traffic for the dashboard
+	Logger     *slog.Logger
+	Router     *mux.Router
+}
+
+// fatal logs an error that keeps the gateway from starting and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
 
 func main() {
 	app := &App{}
 	var err error
 
+	// 0. Structured JSON logs on stdout (LOG_LEVEL debug, info, warn or error)
+	if err := logging.Setup(os.Stdout, os.Getenv("LOG_LEVEL")); err != nil {
+		fatal("Error initializing logging", "error", err)
+	}
+	app.Logger = logging.Component("gateway")
+
 	// 1. Initialize Docker Manager
 	app.Docker, err = docker.NewManager()
 	if err != nil {
-		log.Fatalf("Error initializing Docker manager: %v", err)
+		fatal("Error initializing Docker manager", "error", err)
 	}
 
 	// 2. Initialize Registry Manager
@@ -55,34 +140,186 @@ func main() {
 	os.MkdirAll("./data", 0755)
 	app.Registry, err = registry.NewManager("./data/nanolambda.db")
 	if err != nil {
-		log.Fatalf("Error initializing Registry: %v", err)
+		fatal("Error initializing Registry", "error", err)
 	}
 	defer app.Registry.Close()
 
+	// Trace invocations (TRACING_EXPORTER=otlp to TRACING_ENDPOINT, or file to TRACING_FILE)
+	// TRACING_SAMPLE_RATE is the default fraction traced, trace_sample_rate in nanolambda.yaml overrides it
+	tracingConfig := tracing.Config{
+		Exporter:   os.Getenv("TRACING_EXPORTER"),
+		Endpoint:   os.Getenv("TRACING_ENDPOINT"),
+		File:       os.Getenv("TRACING_FILE"),
+		SampleRate: 1,
+	}
+	if tracingConfig.File == "" {
+		tracingConfig.File = "./data/traces.jsonl"
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("TRACING_SAMPLE_RATE"), 64); err == nil {
+		tracingConfig.SampleRate = v
+	}
+	sampler, shutdownTracing, err := tracing.Setup(context.Background(), "nanolambda-gateway", tracingConfig)
+	if err != nil {
+		fatal("Error initializing tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+	app.Sampler = sampler
+
+	// Lifecycle events streamed on /admin/events, the last EVENTS_BUFFER (default 1000) kept for new subscribers
+	eventsBuffer := 1000
+	if v, err := strconv.Atoi(os.Getenv("EVENTS_BUFFER")); err == nil && v >= 0 {
+		eventsBuffer = v
+	}
+	app.Events = events.NewBus(eventsBuffer)
+
+	// Code store for buildless functions
+	app.Artifacts, err = deploy.NewArtifactStore("./data/artifacts")
+	if err != nil {
+		fatal("Error initializing artifact store", "error", err)
+	}
+
+	// Server-side builds for uploaded bundles
+	app.Deployer = &deploy.Deployer{Docker: app.Docker, Registry: app.Registry, Artifacts: app.Artifacts, Events: app.Events}
+
 	// 3. Initialize Reaper (Scale-to-zero)
 	app.Reaper = reaper.NewManager(app.Docker)
-	// Start reaper in background
+	app.Reaper.SetEvents(app.Events)
+	// Keep a pool of generic runners for buildless functions (WARM_POOL_SIZE, 0 disables)
+	if size, _ := strconv.Atoi(os.Getenv("WARM_POOL_SIZE")); size > 0 {
+		app.Reaper.EnablePool(deploy.BaseImage, size)
+	}
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "warm_pool_idle_containers",
+			Help: "Generic runtime containers waiting in the warm pool",
+		},
+		func() float64 {
+			idle, _ := app.Reaper.PoolStats()
+			return float64(idle)
+		},
+	))
+	// Pause idle containers before stopping them (PAUSE_AFTER seconds, 0 disables)
+	pauseAfter := 5
+	if v := os.Getenv("PAUSE_AFTER"); v != "" {
+		pauseAfter, _ = strconv.Atoi(v)
+	}
+	app.Reaper.SetPauseAfter(time.Duration(pauseAfter) * time.Second)
+
+	// Host budget for function containers (MAX_CONTAINERS, MAX_MEMORY_MB, MIN_HOST_FREE_MB, 0 is unlimited)
+	// idle containers are evicted to make room, starts beyond it get a 503
+	var capacity reaper.CapacityConfig
+	capacity.MaxContainers, _ = strconv.Atoi(os.Getenv("MAX_CONTAINERS"))
+	capacity.MaxMemoryMB, _ = strconv.ParseInt(os.Getenv("MAX_MEMORY_MB"), 10, 64)
+	capacity.MinHostFreeMB, _ = strconv.ParseInt(os.Getenv("MIN_HOST_FREE_MB"), 10, 64)
+	app.Reaper.SetCapacity(capacity)
+
+	// Keep-alive policy (KEEPALIVE_POLICY=fixed|hybrid)
+	switch os.Getenv("KEEPALIVE_POLICY") {
+	case "", "fixed":
+	case "hybrid":
+		config := reaper.DefaultHybridConfig()
+		if v, err := strconv.ParseFloat(os.Getenv("KEEPALIVE_HEAD_PERCENTILE"), 64); err == nil {
+			config.HeadPercentile = v
+		}
+		if v, err := strconv.ParseFloat(os.Getenv("KEEPALIVE_TAIL_PERCENTILE"), 64); err == nil {
+			config.TailPercentile = v
+		}
+		app.Reaper.SetPolicy(reaper.NewHybridPolicy(config))
+	default:
+		fatal("Unknown KEEPALIVE_POLICY (want fixed or hybrid)", "policy", os.Getenv("KEEPALIVE_POLICY"))
+	}
+	app.Reaper.SetLauncher(app.launch)
+
+	// Optional in-process predictor, replaces the prophet service for small deployments
+	// (PREDICTOR_ENABLED=true, PREDICTION_THRESHOLD defaults to 5 requests per 5 minutes)
+	if os.Getenv("PREDICTOR_ENABLED") == "true" {
+		config := predictor.DefaultConfig()
+		if v, err := strconv.ParseFloat(os.Getenv("PREDICTION_THRESHOLD"), 64); err == nil {
+			config.Threshold = v
+		}
+		app.Predictor = predictor.NewPredictor(config, app.Registry, func(ctx context.Context, name string) error {
+			result := app.warmup(ctx, WarmupTarget{Function: name, Replicas: 1, TTLSeconds: DefaultWarmupTTL}, true)
+			if result.Status == "failed" {
+				return errors.New(strings.Join(result.Errors, "; "))
+			}
+			return nil
+		})
+	}
+
+	// Size replica pools to in-flight requests (AUTOSCALE_TARGET_CONCURRENCY, AUTOSCALE_MAX_REPLICAS
+	// are the defaults for functions without target_concurrency or max_instances)
+	scaling := reaper.DefaultAutoscalerConfig()
+	if v, err := strconv.Atoi(os.Getenv("AUTOSCALE_TARGET_CONCURRENCY")); err == nil && v > 0 {
+		scaling.DefaultTarget = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("AUTOSCALE_MAX_REPLICAS")); err == nil && v > 0 {
+		scaling.DefaultMax = v
+	}
+	app.Autoscaler = reaper.NewAutoscaler(app.Reaper, scaling)
+
+	// Collect cpu, memory and network usage of every tracked container
+	app.Stats = app.Docker.NewStatsCollector()
+	go app.Stats.Run(context.Background(), 5*time.Second, app.Reaper.StatsTargets)
+
+	// Meter GB-seconds of invocations and of warm idle containers, rolled up hourly in the registry
+	app.Meter = metering.NewMeter(app.Registry)
+	app.Reaper.SetIdleRecorder(app.Meter.Idle)
+	go app.Meter.Start(context.Background(), time.Minute)
+
+	// Record every invocation, with a sample of request and response bodies (HISTORY_* settings)
+	app.History = history.NewRecorder(historyConfig(), app.Registry)
+	go app.History.Start(context.Background())
+	app.Live = newLiveStats()
+
+	// Fail fast for functions that keep failing to start or erroring, probing them in the background
+	app.Breakers = breaker.NewSet(breaker.DefaultConfig())
+	app.Breakers.SetEvents(app.Events)
+	go app.Breakers.Start(context.Background(), func(ctx context.Context, key string) error {
+		return app.launch(ctx, key, reaper.OriginProbe)
+	})
+
+	// Start what can launch containers only now, app.launch needs everything above
 	go app.Reaper.Start(context.Background())
+	go app.Autoscaler.Start(context.Background())
+	// Keep each function's minimum instances running (min_instances and schedule in nanolambda.yaml)
+	go app.syncScaling(context.Background())
+	if app.Predictor != nil {
+		go app.Predictor.Start(context.Background())
+	}
 
 	// 4. Initialize Router
 	app.Router = mux.NewRouter()
-	
+
 	// 5. Define Routes
 	app.Router.Handle("/metrics", promhttp.Handler())
 	app.Router.HandleFunc("/admin/health", app.HealthCheckHandler).Methods("GET")
 	app.Router.HandleFunc("/function/{name}", app.InvokeHandler).Methods("POST")
-	
+
 	// Admin Routes
 	app.Router.HandleFunc("/admin/warmup", app.WarmupHandler).Methods("POST")
-	
+	app.Router.HandleFunc("/admin/warmup/stats", app.WarmupStatsHandler).Methods("GET")
+	app.Router.HandleFunc("/admin/events", app.EventsHandler).Methods("GET")
+	app.Router.HandleFunc("/admin/functions", app.FunctionsHandler).Methods("GET")
+	app.Router.HandleFunc("/admin/functions/{name}", app.DescribeHandler).Methods("GET")
+	app.Router.HandleFunc("/admin/functions/{name}/deploy", app.DeployHandler).Methods("POST")
+	app.Router.HandleFunc("/admin/deployments/{id}", app.DeploymentHandler).Methods("GET")
+	app.Router.HandleFunc("/admin/deployments/{id}/logs", app.DeploymentLogsHandler).Methods("GET")
+	app.Router.HandleFunc("/admin/containers", app.ContainersHandler).Methods("GET")
+	app.Router.HandleFunc("/admin/invocations", app.InvocationsHandler).Methods("GET")
+	app.Router.HandleFunc("/admin/metrics/summary", app.MetricsSummaryHandler).Methods("GET")
+
+	// Dashboard, served from the binary
+	app.Router.Handle("/admin/dashboard", http.RedirectHandler("/admin/dashboard/", http.StatusMovedPermanently))
+	app.Router.PathPrefix("/admin/dashboard/").Handler(dashboardHandler())
+
 	// 6. Start Server
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	fmt.Printf("Gateway running on port %s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, app.Router))
+	app.Logger.Info("Gateway running", "port", port)
+	fatal("Gateway stopped", "error", http.ListenAndServe(":"+port, app.Router))
 }
 
 // HealthCheckHandler returns simple status
@@ -94,101 +331,167 @@ func (app *App) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 // InvokeHandler handles function invocation
 func (app *App) InvokeHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	funcName := vars["name"]
+	funcName := vars["name"] // "name" or "name@version" to pin a version
 
-	httpRequestsTotal.WithLabelValues(funcName, "invoked").Inc()
+	// Accept the caller's request ID or assign one
+	// it is returned in the response, forwarded to the function and added to every log line
+	requestID := r.Header.Get(logging.RequestIDHeader)
+	if !logging.ValidRequestID(requestID) {
+		requestID = logging.NewRequestID()
+		r.Header.Set(logging.RequestIDHeader, requestID)
+	}
+	w.Header().Set(logging.RequestIDHeader, requestID)
 
-	// 1. Check if function is already running (Hot Start)
-	addr, running := app.Reaper.GetContainer(funcName)
-	
-	if running {
-		// Update last accessed time
-		app.Reaper.Touch(funcName)
-	} else {
-		// Cold Start Logic
-		// fmt.Printf("Cold start for %s...\n", funcName)
-		
-		// Fetch function metadata
-		fn, err := app.Registry.GetFunction(funcName)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Function '%s' not found", funcName), http.StatusNotFound)
-			return
-		}
+	// Continue the caller's trace, if it sent a traceparent
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, "invoke "+funcName,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(tracing.FunctionKey.String(funcName), attribute.String("request_id", requestID)))
+	ctx = logging.WithRequestID(ctx, requestID)
+	r = r.WithContext(ctx)
 
-		// Start Container
-		var id string
-		addr, id, err = app.Docker.StartContainer(r.Context(), fn.ImageTag, fn.Name)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to start container: %v", err), http.StatusInternalServerError)
-			return
+	// Record the real response status and latency, whatever path the request takes
+	started := time.Now()
+	start := registry.StartWarm
+	rec := newStatusRecorder(w)
+	w = rec
+
+	// Keep the start of both bodies for the invocation history
+	rec.capture(app.History.MaxBodyBytes())
+	requestBody, requestTruncated := captureRequest(r, app.History.MaxBodyBytes())
+	var containerID string
+	name, version, _ := parseTarget(funcName)
+
+	// Only functions that exist get per-function metrics, state and history, so random names can't grow them
+	_, lookup := tracer.Start(ctx, "registry.lookup")
+	fn, lookupErr := app.resolve(funcName)
+	tracing.End(lookup, lookupErr)
+	known := lookupErr == nil
+
+	defer func() {
+		if known {
+			httpRequestsTotal.WithLabelValues(funcName, statusClass(rec.status)).Inc()
+			invocationDuration.WithLabelValues(funcName, start).Observe(time.Since(started).Seconds())
+			app.Live.record(funcName, rec.status, start == registry.StartCold, time.Since(started))
 		}
 
-		// Wait for Container to be Ready
-		ready := false
-		for i := 0; i < 20; i++ {
-			resp, err := http.Get(fmt.Sprintf("http://%s/health", addr))
-			if err == nil && resp.StatusCode == 200 {
-				ready = true
-				resp.Body.Close()
-				break
-			}
-			time.Sleep(100 * time.Millisecond)
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", rec.status),
+			attribute.Bool("faas.coldstart", start == registry.StartCold),
+		)
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
 		}
+		span.End()
+
+		app.Logger.InfoContext(ctx, "invocation",
+			"function", funcName,
+			"status", rec.status,
+			"start", start,
+			"duration_ms", float64(time.Since(started).Microseconds())/1000,
+		)
 
-		if !ready {
-			// Clean up if it failed to start properly
-			app.Docker.StopContainer(r.Context(), id)
-			http.Error(w, "Container timed out starting", http.StatusGatewayTimeout)
+		if !known {
+			unknownFunctionRequests.Inc()
 			return
 		}
+		app.History.Record(registry.Invocation{
+			RequestID:         requestID,
+			Function:          name,
+			Version:           version,
+			StartedAt:         started,
+			Duration:          time.Since(started),
+			Status:            rec.status,
+			Start:             start,
+			ContainerID:       containerID,
+			RequestBody:       requestBody,
+			ResponseBody:      rec.body,
+			RequestTruncated:  requestTruncated,
+			ResponseTruncated: rec.truncated,
+		})
+	}()
 
-		// Register with Reaper
-		app.Reaper.Register(funcName, id, addr, fn.Timeout)
+	if !known {
+		http.Error(w, fmt.Sprintf("Function '%s' not found", funcName), http.StatusNotFound)
+		return
 	}
 
-	// 2. Proxy Request
-	p := proxy.NewReverseProxy(addr)
-	p.ServeHTTP(w, r)
-}
+	app.Predictor.Record(funcName)
 
-// WarmupHandler handles pre-warming requests from AI
-func (app *App) WarmupHandler(w http.ResponseWriter, r *http.Request) {
-	// Simple implementation: Just trigger a "start" without invocation
-	var req struct {
-		Function string `json:"function"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	// Fail fast while the function's circuit is open
+	if err := app.Breakers.Allow(funcName); err != nil {
+		var open *breaker.OpenError
+		errors.As(err, &open)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(int(open.RetryAfter.Seconds())))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":        "circuit open",
+			"function":     funcName,
+			"last_failure": open.LastFailure,
+		})
 		return
 	}
+	app.Reaper.RecordInvocation(funcName)
 
-	// Check if already running
-	_, running := app.Reaper.GetContainer(req.Function)
-	if running {
-		app.Reaper.Touch(req.Function) // Extend life
-		json.NewEncoder(w).Encode(map[string]string{"status": "already_running"})
-		return
-	}
+	// 1. Check if function is already running (Hot Start)
+	addr, running := app.Reaper.GetContainer(ctx, funcName)
 
-	// Start it
-	fn, err := app.Registry.GetFunction(req.Function)
-	if err != nil {
-		http.Error(w, "Function not found", http.StatusNotFound)
-		return
-	}
+	if !running {
+		// Cold Start Logic
+		// fmt.Printf("Cold start for %s...\n", funcName)
 
-	addr, id, err := app.Docker.StartContainer(r.Context(), fn.ImageTag, fn.Name)
-	if err != nil {
-		http.Error(w, "Failed to start", http.StatusInternalServerError)
-		return
+		start = registry.StartCold
+		coldStart := time.Now()
+
+		// Prefer a pooled runner: only the code has to be loaded
+		var timing coldStartTiming
+		if pooled, pooledTiming, ok := app.claimPooled(r.Context(), funcName, fn, fn.Timeout, reaper.OriginRequest); ok {
+			addr, timing = pooled, pooledTiming
+			coldStartDuration.WithLabelValues(funcName, "pool").Observe(time.Since(coldStart).Seconds())
+			coldStartsTotal.WithLabelValues(funcName, "pool").Inc()
+		} else {
+			var serr *startError
+			addr, timing, serr = app.startReplica(r.Context(), funcName, fn, fn.Timeout, reaper.OriginRequest)
+			if serr != nil {
+				if serr.status == http.StatusServiceUnavailable {
+					w.Header().Set("Retry-After", "1")
+				} else {
+					app.Breakers.StartFailed(ctx, funcName, serr)
+				}
+				http.Error(w, serr.msg, serr.status)
+				return
+			}
+			app.Breakers.StartSucceeded(funcName)
+			coldStartDuration.WithLabelValues(funcName, "regular").Observe(time.Since(coldStart).Seconds())
+			coldStartsTotal.WithLabelValues(funcName, "regular").Inc()
+		}
+		if len(timing) > 0 {
+			// Per-phase cold start breakdown for the caller (pull, create, start, ready or load)
+			w.Header().Set("Server-Timing", timing.serverTiming())
+		}
+		app.Reaper.Acquire(funcName, addr)
 	}
+	// Count the request against the replica until it completes (for the autoscaler)
+	defer app.Reaper.Release(funcName, addr)
 
-	// Wait for ready (simplified)
-	time.Sleep(200 * time.Millisecond) // Give it a tiny headstart, real healthcheck is better but blocking here is ok for admin api
+	// 2. Proxy Request
+	// The function sees the proxy span as its parent through traceparent
+	proxyCtx, proxySpan := tracer.Start(ctx, "proxy", trace.WithSpanKind(trace.SpanKindClient))
+	otel.GetTextMapPropagator().Inject(proxyCtx, propagation.HeaderCarrier(r.Header))
+	p := proxy.NewReverseProxy(addr)
+	proxied := time.Now()
+	p.ServeHTTP(w, r.WithContext(proxyCtx))
+	proxySpan.SetAttributes(attribute.String("server.address", addr), attribute.Int("http.response.status_code", rec.status))
+	if rec.status >= 500 {
+		proxySpan.SetStatus(codes.Error, http.StatusText(rec.status))
+	}
+	proxySpan.End()
+	app.Breakers.Result(ctx, funcName, rec.status)
 
-	// Use a longer timeout for warmup (e.g. 5 minutes) to ensure it's ready for the predicted spike
-	const WarmupTimeout = 300
-	app.Reaper.Register(req.Function, id, addr, WarmupTimeout)
-	
-	json.NewEncoder(w).Encode(map[string]string{"status": "warmed_up"})
-}
\ No newline at end of file
+	// Bill the time the function spent on the request against its configured memory
+	if info, ok := app.Reaper.Replica(funcName, addr); ok {
+		app.Meter.Invocation(funcName, time.Since(proxied), info.MemoryLimit)
+		containerID, version = info.ID, info.Version
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/logging"
+	"github.com/nikhi/nanolambda/pkg/reaper"
+)
+
+// scalingSyncInterval is how often function minimums, schedules and autoscaling bounds are re-read
+// schedules have minute resolution, so this only needs to be well under a minute
+const scalingSyncInterval = 15 * time.Second
+
+// syncScaling keeps the reaper's replica floors, the autoscaler's bounds and the trace
+// sample rates in line with the registry and each function's time-of-day schedule until ctx is done
+func (app *App) syncScaling(ctx context.Context) {
+	ticker := time.NewTicker(scalingSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		fns, err := app.Registry.ListFunctions()
+		if err != nil {
+			logging.Component("registry").Error("Error loading scaling settings", "error", err)
+		} else {
+			now := time.Now()
+			floors := make(map[string]int)
+			bounds := make(map[string]reaper.Bounds)
+			sampleRates := make(map[string]float64)
+			for _, fn := range fns {
+				floor := fn.MinInstancesAt(now)
+				if floor > 0 {
+					floors[fn.Name] = floor
+				}
+				bounds[fn.Name] = reaper.Bounds{Target: fn.TargetConcurrency, Min: floor, Max: fn.MaxInstances}
+				if fn.TraceSampleRate != nil {
+					sampleRates[fn.Name] = *fn.TraceSampleRate
+				}
+			}
+			app.Reaper.SetMinInstances(floors)
+			app.Autoscaler.SetBounds(bounds)
+			app.Sampler.SetRates(sampleRates)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
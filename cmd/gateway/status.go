@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// statusRecorder remembers the status code written through it
+// and, after capture, the start of the response body
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+
+	body      []byte
+	limit     int
+	truncated bool
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// capture keeps up to limit bytes of the response body
+func (r *statusRecorder) capture(limit int) {
+	r.limit = limit
+	r.body = []byte{}
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.body != nil {
+		keep := min(len(b), r.limit-len(r.body))
+		r.body = append(r.body, b[:keep]...)
+		r.truncated = r.truncated || keep < len(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// captureRequest reads up to limit bytes of the request body for the history
+// and puts them back so the function still receives the whole body
+func captureRequest(req *http.Request, limit int) ([]byte, bool) {
+	if req.Body == nil {
+		return []byte{}, false
+	}
+	head, _ := io.ReadAll(io.LimitReader(req.Body, int64(limit)+1))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), req.Body), req.Body}
+	if len(head) > limit {
+		return head[:limit:limit], true
+	}
+	return head, false
+}
+
+// statusClass groups a status code for metrics labels ("2xx", "5xx", ...)
+func statusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// Flush lets streamed responses through the proxy
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
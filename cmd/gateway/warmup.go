@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/nikhi/nanolambda/pkg/events"
+	"github.com/nikhi/nanolambda/pkg/reaper"
+)
+
+// DefaultWarmupTTL is how long a warmed replica is kept without traffic (seconds)
+// long enough to still be there for the predicted spike
+const DefaultWarmupTTL = 300
+
+// WarmupTarget asks for a number of warm replicas of a function
+type WarmupTarget struct {
+	Function   string `json:"function"`
+	Replicas   int    `json:"replicas,omitempty"`    // default 1
+	TTLSeconds int    `json:"ttl_seconds,omitempty"` // default DefaultWarmupTTL
+	Version    int    `json:"version,omitempty"`     // default latest
+}
+
+// WarmupRequest is the body of POST /admin/warmup
+// a single {"function": ...} target is still accepted, as is a bare list of targets
+type WarmupRequest struct {
+	WarmupTarget
+	Targets []WarmupTarget `json:"targets,omitempty"`
+	Wait    bool           `json:"wait,omitempty"` // block until every replica is ready or failed
+}
+
+// WarmupResult reports what happened to one target
+type WarmupResult struct {
+	Function    string   `json:"function"`
+	Version     int      `json:"version,omitempty"`
+	Status      string   `json:"status"` // already_warm, warmed, starting, partial or failed
+	Requested   int      `json:"requested"`
+	AlreadyWarm int      `json:"already_warm"`
+	Warmed      int      `json:"warmed"`
+	Starting    int      `json:"starting,omitempty"`
+	Failed      int      `json:"failed"`
+	Errors      []string `json:"errors,omitempty"`
+
+	notFound bool
+}
+
+// WarmupHandler handles pre-warming requests from AI
+func (app *App) WarmupHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeWarmupRequest(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// warmups outlive the request when the caller doesn't wait for them
+	targets, wait := req.Targets, req.Wait
+	ctx := r.Context()
+	if !wait {
+		ctx = context.Background()
+	}
+
+	results := make([]WarmupResult, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t WarmupTarget) {
+			defer wg.Done()
+			results[i] = app.warmup(ctx, t, wait)
+		}(i, t)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	// a single {"function": ...} that failed keeps the old status codes, older callers only check those
+	if req.Function != "" && len(targets) == 1 && results[0].Status == "failed" {
+		if results[0].notFound {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+	json.NewEncoder(w).Encode(map[string][]WarmupResult{"results": results})
+}
+
+// decodewarmuprequest parses any of the accepted bodies into a request whose targets
+// include the top-level one, with defaults filled in
+func decodeWarmupRequest(body io.Reader) (WarmupRequest, error) {
+	var req WarmupRequest
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return req, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &req.Targets)
+	} else {
+		err = json.Unmarshal(data, &req)
+	}
+	if err != nil {
+		return req, err
+	}
+
+	if req.Function != "" {
+		req.Targets = append(req.Targets, req.WarmupTarget)
+	}
+	if len(req.Targets) == 0 {
+		return req, fmt.Errorf("no targets")
+	}
+	targets := req.Targets
+	for i := range targets {
+		if targets[i].Function == "" {
+			return req, fmt.Errorf("target %d has no function", i)
+		}
+		if targets[i].Replicas <= 0 {
+			targets[i].Replicas = 1
+		}
+		if targets[i].TTLSeconds <= 0 {
+			targets[i].TTLSeconds = DefaultWarmupTTL
+		}
+	}
+	return req, nil
+}
+
+// warmup brings a function up to the requested number of ready replicas
+// replicas that are already running count toward the target and have their life extended
+// without wait, missing replicas are started in the background and reported as starting
+func (app *App) warmup(ctx context.Context, t WarmupTarget, wait bool) WarmupResult {
+	key := targetKey(t.Function, t.Version)
+	result := WarmupResult{Function: t.Function, Version: t.Version, Requested: t.Replicas}
+
+	fn, err := app.resolve(key)
+	if err != nil {
+		result.Failed = t.Replicas
+		result.Errors = []string{fmt.Sprintf("function '%s' not found", key)}
+		result.Status = "failed"
+		result.notFound = true
+		return result
+	}
+
+	// Count what's already running or starting, and reserve the rest so concurrent warmups don't start them twice
+	running, starting, missing := app.Reaper.ReserveWarmup(key, t.Replicas)
+	result.AlreadyWarm = running
+	result.Starting = starting
+	if missing == 0 {
+		result.Status = "already_warm"
+		if starting > 0 {
+			result.Status = "starting"
+		}
+		return result
+	}
+
+	app.Events.Publish(events.Event{Type: events.WarmupTriggered, Function: key, Version: fn.Version, Origin: reaper.OriginWarmup, Replicas: missing})
+
+	// Start the missing replicas in parallel, each one passes the function's readiness probe like a cold start
+	errs := make(chan string, missing)
+	var wg sync.WaitGroup
+	for i := 0; i < missing; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer app.Reaper.WarmupDone(key)
+			if _, _, ok := app.claimPooled(ctx, key, fn, t.TTLSeconds, reaper.OriginWarmup); ok {
+				errs <- ""
+				return
+			}
+			if _, _, serr := app.startReplica(ctx, key, fn, t.TTLSeconds, reaper.OriginWarmup); serr != nil {
+				errs <- serr.msg
+				return
+			}
+			errs <- ""
+		}()
+	}
+
+	if !wait {
+		result.Starting += missing
+		result.Status = "starting"
+		return result
+	}
+
+	wg.Wait()
+	close(errs)
+	for msg := range errs {
+		if msg == "" {
+			result.Warmed++
+			continue
+		}
+		result.Failed++
+		result.Errors = append(result.Errors, msg)
+	}
+
+	switch {
+	case result.Failed == 0:
+		result.Status = "warmed"
+	case result.Failed == missing && result.AlreadyWarm == 0 && result.Starting == 0:
+		result.Status = "failed"
+	default:
+		result.Status = "partial"
+	}
+	return result
+}
+
+// WarmupStatsHandler reports per-function warmup hits, wasted warmups and missed predictions
+func (app *App) WarmupStatsHandler(w http.ResponseWriter, r *http.Request) {
+	type functionStats struct {
+		reaper.WarmupStats
+		HitRate float64 `json:"hit_rate"` // hits / (hits + wasted)
+	}
+
+	out := make(map[string]functionStats)
+	for name, stats := range app.Reaper.WarmupStats() {
+		fs := functionStats{WarmupStats: stats}
+		if total := stats.Hits + stats.Wasted; total > 0 {
+			fs.HitRate = float64(stats.Hits) / float64(total)
+		}
+		out[name] = fs
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,318 @@
+package breaker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/events"
+	"github.com/nikhi/nanolambda/pkg/logging"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// breaker states
+const (
+	StateClosed   = "closed"    // requests go through
+	StateOpen     = "open"      // requests fail fast until the next probe
+	StateHalfOpen = "half_open" // a probe is checking whether the function recovered
+)
+
+var (
+	breakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "breaker_state",
+			Help: "Circuit breaker state per function (0 closed, 1 half-open, 2 open)",
+		},
+		[]string{"function"},
+	)
+	breakerRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "breaker_rejections_total",
+			Help: "Requests failed fast because the function's circuit was open",
+		},
+		[]string{"function"},
+	)
+	breakerTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "breaker_transitions_total",
+			Help: "Circuit breaker state changes, by the state entered",
+		},
+		[]string{"function", "state"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(breakerState, breakerRejections, breakerTransitions)
+}
+
+var stateValues = map[string]float64{StateClosed: 0, StateHalfOpen: 1, StateOpen: 2}
+
+// config tunes when circuits open and how often they are probed
+type Config struct {
+	StartFailures int           // consecutive failed container starts that open the circuit
+	ErrorRate     float64       // fraction of 5xx responses that opens the circuit
+	Window        int           // number of recent responses the error rate is computed over
+	MinRequests   int           // responses needed in the window before the error rate counts
+	ProbeInterval time.Duration // how long an open circuit waits before a half-open probe
+}
+
+// defaultconfig opens after 3 failed starts in a row or 50% errors over the last 20 responses,
+// and probes every 30 seconds
+func DefaultConfig() Config {
+	return Config{
+		StartFailures: 3,
+		ErrorRate:     0.5,
+		Window:        20,
+		MinRequests:   10,
+		ProbeInterval: 30 * time.Second,
+	}
+}
+
+// prober tries to bring a function up, e.g. by starting a container
+type Prober func(ctx context.Context, function string) error
+
+// status is a snapshot of one function's breaker
+type Status struct {
+	State         string    `json:"state"`
+	StartFailures int       `json:"consecutive_start_failures"`
+	ErrorRate     float64   `json:"error_rate"`
+	LastFailure   string    `json:"last_failure,omitempty"`
+	OpenedAt      time.Time `json:"opened_at,omitempty"`
+	NextProbe     time.Time `json:"next_probe,omitempty"`
+}
+
+// openerror is returned for requests to a function whose circuit is open
+type OpenError struct {
+	Function    string
+	LastFailure string
+	RetryAfter  time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit open for %s: %s", e.Function, e.LastFailure)
+}
+
+// set holds one circuit breaker per function
+type Set struct {
+	config   Config
+	mu       sync.Mutex
+	breakers map[string]*breaker
+	logger   *slog.Logger
+	events   *events.Bus
+}
+
+type breaker struct {
+	state         string
+	startFailures int
+	results       []bool // recent responses, true for 5xx
+	next          int
+	lastFailure   string
+	openedAt      time.Time
+}
+
+// newset creates an empty set of breakers
+func NewSet(config Config) *Set {
+	return &Set{
+		config:   config,
+		breakers: make(map[string]*breaker),
+		logger:   logging.Component("breaker"),
+	}
+}
+
+// setevents sets where breaker openings and closings are published
+func (s *Set) SetEvents(b *events.Bus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = b
+}
+
+// get returns the breaker of a function, creating a closed one
+// callers must hold s.mu
+func (s *Set) get(function string) *breaker {
+	b, ok := s.breakers[function]
+	if !ok {
+		b = &breaker{state: StateClosed}
+		s.breakers[function] = b
+	}
+	return b
+}
+
+// allow returns an *OpenError if requests to the function should fail fast
+func (s *Set) Allow(function string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.breakers[function]
+	if !ok || b.state == StateClosed {
+		return nil
+	}
+	breakerRejections.WithLabelValues(function).Inc()
+	retry := time.Until(b.openedAt.Add(s.config.ProbeInterval))
+	if retry < time.Second {
+		retry = time.Second
+	}
+	return &OpenError{Function: function, LastFailure: b.lastFailure, RetryAfter: retry}
+}
+
+// startfailed records a container start that failed
+func (s *Set) StartFailed(ctx context.Context, function string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.get(function)
+	b.startFailures++
+	b.lastFailure = fmt.Sprintf("container start failed: %v", err)
+	if b.state == StateClosed && b.startFailures >= s.config.StartFailures {
+		s.open(ctx, function, b)
+	}
+}
+
+// startsucceeded records a container that started and passed its readiness probe
+func (s *Set) StartSucceeded(function string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.breakers[function]; ok {
+		b.startFailures = 0
+	}
+}
+
+// result records the status code of a proxied response
+func (s *Set) Result(ctx context.Context, function string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.get(function)
+	if b.state != StateClosed {
+		return
+	}
+	failed := status >= 500
+	if failed {
+		b.lastFailure = fmt.Sprintf("function returned %d", status)
+	}
+	if len(b.results) < s.config.Window {
+		b.results = append(b.results, failed)
+	} else {
+		b.results[b.next] = failed
+		b.next = (b.next + 1) % s.config.Window
+	}
+	if len(b.results) >= s.config.MinRequests && b.errorRate() >= s.config.ErrorRate {
+		s.open(ctx, function, b)
+	}
+}
+
+// errorrate returns the fraction of 5xx responses in the window
+func (b *breaker) errorRate() float64 {
+	if len(b.results) == 0 {
+		return 0
+	}
+	failed := 0
+	for _, f := range b.results {
+		if f {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(b.results))
+}
+
+// open trips a breaker
+// callers must hold s.mu
+func (s *Set) open(ctx context.Context, function string, b *breaker) {
+	s.logger.WarnContext(ctx, "opening circuit", "function", function, "last_failure", b.lastFailure)
+	b.openedAt = time.Now()
+	s.transition(function, b, StateOpen)
+	s.events.Publish(events.Event{Type: events.BreakerOpened, Function: function, Reason: b.lastFailure})
+}
+
+// close resets a breaker after a successful probe
+// callers must hold s.mu
+func (s *Set) close(ctx context.Context, function string, b *breaker) {
+	s.logger.InfoContext(ctx, "closing circuit", "function", function)
+	b.startFailures = 0
+	b.results = nil
+	b.next = 0
+	s.transition(function, b, StateClosed)
+	s.events.Publish(events.Event{Type: events.BreakerClosed, Function: function})
+}
+
+// callers must hold s.mu
+func (s *Set) transition(function string, b *breaker, state string) {
+	b.state = state
+	breakerState.WithLabelValues(function).Set(stateValues[state])
+	breakerTransitions.WithLabelValues(function, state).Inc()
+}
+
+// status returns a snapshot of a function's breaker
+func (s *Set) Status(function string) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.breakers[function]
+	if !ok {
+		return Status{State: StateClosed}
+	}
+	st := Status{
+		State:         b.state,
+		StartFailures: b.startFailures,
+		ErrorRate:     b.errorRate(),
+		LastFailure:   b.lastFailure,
+	}
+	if b.state != StateClosed {
+		st.OpenedAt = b.openedAt
+		st.NextProbe = b.openedAt.Add(s.config.ProbeInterval)
+	}
+	return st
+}
+
+// start runs half-open probes for open circuits until ctx is done
+// a successful probe closes the circuit, a failed one keeps it open for another interval
+func (s *Set) Start(ctx context.Context, probe Prober) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, function := range s.halfOpen() {
+			go s.probe(ctx, probe, function)
+		}
+	}
+}
+
+// halfOpen moves the open circuits that are due a probe to half-open and returns their functions
+func (s *Set) halfOpen() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []string
+	for function, b := range s.breakers {
+		if b.state == StateOpen && time.Since(b.openedAt) >= s.config.ProbeInterval {
+			s.transition(function, b, StateHalfOpen)
+			due = append(due, function)
+		}
+	}
+	return due
+}
+
+func (s *Set) probe(ctx context.Context, probe Prober, function string) {
+	s.logger.InfoContext(ctx, "probing", "function", function)
+	err := probe(ctx, function)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.get(function)
+	if err != nil {
+		s.logger.WarnContext(ctx, "probe failed", "function", function, "error", err)
+		b.lastFailure = fmt.Sprintf("probe failed: %v", err)
+		b.openedAt = time.Now()
+		s.transition(function, b, StateOpen)
+		return
+	}
+	s.close(ctx, function, b)
+}
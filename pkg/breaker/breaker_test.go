@@ -0,0 +1,87 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func isOpen(s *Set, function string) bool {
+	var open *OpenError
+	return errors.As(s.Allow(function), &open)
+}
+
+func TestStartFailuresOpenTheCircuit(t *testing.T) {
+	s := NewSet(DefaultConfig())
+	ctx := context.Background()
+	failed := errors.New("no such image")
+
+	s.StartFailed(ctx, "fn", failed)
+	s.StartFailed(ctx, "fn", failed)
+	s.StartSucceeded("fn")
+	s.StartFailed(ctx, "fn", failed)
+	if isOpen(s, "fn") {
+		t.Fatal("opened although a start succeeded in between")
+	}
+
+	s.StartFailed(ctx, "fn", failed)
+	s.StartFailed(ctx, "fn", failed)
+	if !isOpen(s, "fn") {
+		t.Fatal("still closed after 3 failed starts in a row")
+	}
+	if isOpen(s, "other") {
+		t.Error("an unrelated function was rejected")
+	}
+}
+
+func TestErrorRateOpensTheCircuit(t *testing.T) {
+	s := NewSet(DefaultConfig())
+	ctx := context.Background()
+
+	// client errors are the caller's fault
+	for i := 0; i < 20; i++ {
+		s.Result(ctx, "fn", 404)
+	}
+	for i := 0; i < 9; i++ {
+		s.Result(ctx, "fn", 500)
+	}
+	if isOpen(s, "fn") {
+		t.Fatal("opened at 9 errors in a window of 20")
+	}
+
+	s.Result(ctx, "fn", 502)
+	if !isOpen(s, "fn") {
+		t.Fatal("still closed at 10 errors in a window of 20")
+	}
+}
+
+func TestProbeClosesTheCircuit(t *testing.T) {
+	config := DefaultConfig()
+	config.ProbeInterval = 0 // open circuits are due a probe straight away
+	s := NewSet(config)
+	ctx := context.Background()
+	for i := 0; i < config.StartFailures; i++ {
+		s.StartFailed(ctx, "fn", errors.New("no such image"))
+	}
+
+	if due := s.halfOpen(); len(due) != 1 || due[0] != "fn" {
+		t.Fatalf("due for a probe: %v, want [fn]", due)
+	}
+	if !isOpen(s, "fn") {
+		t.Fatal("requests allowed while half-open")
+	}
+
+	s.probe(ctx, func(context.Context, string) error { return errors.New("still broken") }, "fn")
+	if st := s.Status("fn"); st.State != StateOpen {
+		t.Fatalf("after a failed probe: %s, want %s", st.State, StateOpen)
+	}
+
+	s.halfOpen()
+	s.probe(ctx, func(context.Context, string) error { return nil }, "fn")
+	if st := s.Status("fn"); st.State != StateClosed || st.StartFailures != 0 {
+		t.Fatalf("after a successful probe: %+v, want closed with no failures", st)
+	}
+	if isOpen(s, "fn") {
+		t.Error("requests rejected after the circuit closed")
+	}
+}
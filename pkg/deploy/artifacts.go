@@ -0,0 +1,79 @@
+package deploy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nikhi/nanolambda/pkg/docker"
+)
+
+// artifactstore keeps function code as content-addressed tarballs on disk
+type ArtifactStore struct {
+	Dir string
+}
+
+// newartifactstore creates a store rooted at dir
+func NewArtifactStore(dir string) (*ArtifactStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact store: %w", err)
+	}
+	return &ArtifactStore{Dir: dir}, nil
+}
+
+// put stores the contents of a function directory
+// returns the artifact digest (sha256:...) and its size in bytes
+// storing the same code twice is a no-op
+func (s *ArtifactStore) Put(dir string) (string, int64, error) {
+	var buf bytes.Buffer
+	if err := docker.TarCode(dir, &buf); err != nil {
+		return "", 0, err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	size := int64(buf.Len())
+
+	path := s.path(digest)
+	if _, err := os.Stat(path); err == nil {
+		return digest, size, nil
+	}
+
+	// write to a temp file first so a half-written artifact is never visible
+	tmp, err := os.CreateTemp(s.Dir, ".artifact-")
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := io.Copy(tmp, &buf); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	return digest, size, nil
+}
+
+// open returns a reader for the tarball of an artifact
+func (s *ArtifactStore) Open(digest string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(digest))
+	if err != nil {
+		return nil, fmt.Errorf("artifact %s not found: %w", digest, err)
+	}
+	return f, nil
+}
+
+func (s *ArtifactStore) path(digest string) string {
+	return filepath.Join(s.Dir, strings.Replace(digest, ":", "-", 1)+".tar")
+}
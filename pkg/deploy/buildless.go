@@ -0,0 +1,60 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// baseimage is the shared runtime image buildless python functions run on
+const BaseImage = "nanolambda/base-python:3.11"
+
+// defaultdockerfile is what `nanolambda init` generates for python functions
+// a function whose dockerfile is still this template doesn't need its own image
+const DefaultDockerfile = `FROM nanolambda/base-python:3.11
+
+COPY handler.py /function/handler.py
+# If you have requirements.txt, uncomment:
+# COPY requirements.txt /function/requirements.txt
+# RUN pip install -r /function/requirements.txt
+`
+
+// isbuildless reports whether the function in dir can skip the image build
+// and run its code directly on the base image
+func IsBuildless(dir string, config *Config) (bool, error) {
+	if config.Runtime != "python" {
+		return false, nil
+	}
+
+	dockerfile, err := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	switch {
+	case os.IsNotExist(err):
+		// no dockerfile at all: only possible without dependencies to install
+		if _, err := os.Stat(filepath.Join(dir, "requirements.txt")); err == nil {
+			return false, fmt.Errorf("requirements.txt found but no Dockerfile to install it")
+		}
+	case err != nil:
+		return false, err
+	case normalizeDockerfile(string(dockerfile)) != normalizeDockerfile(DefaultDockerfile):
+		return false, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "handler.py")); err != nil {
+		return false, fmt.Errorf("handler.py not found")
+	}
+	return true, nil
+}
+
+// normalizedockerfile drops blank lines, line endings and trailing space
+// so a template saved on windows still compares equal
+func normalizeDockerfile(s string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,126 @@
+package deploy
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nikhi/nanolambda/pkg/docker"
+)
+
+// maxbundlefilesize caps a single extracted file so a bundle can't fill the disk
+const MaxBundleFileSize = 256 << 20
+
+// maxbundleextractedsize caps the extracted size of a whole bundle, a small upload can expand a lot
+const MaxBundleExtractedSize = 1 << 30
+
+// maxbundleentries caps the number of files and directories in a bundle
+const MaxBundleEntries = 10000
+
+// bundlelimits bounds what a bundle may expand to
+type bundleLimits struct {
+	fileSize  int64
+	extracted int64
+	entries   int
+}
+
+var defaultBundleLimits = bundleLimits{fileSize: MaxBundleFileSize, extracted: MaxBundleExtractedSize, entries: MaxBundleEntries}
+
+// packbundle writes dir as a gzipped tar, skipping files excluded by .dockerignore
+func PackBundle(dir string, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	if err := docker.TarContext(dir, gz); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// extractbundle unpacks a gzipped tar produced by packbundle into dir
+// entries that would escape dir are rejected, as are bundles past the size and entry limits
+func ExtractBundle(r io.Reader, dir string) error {
+	return extractBundle(r, dir, defaultBundleLimits)
+}
+
+func extractBundle(r io.Reader, dir string, limits bundleLimits) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("bundle is not gzip compressed: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	var entries int
+	var extracted int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid bundle: %w", err)
+		}
+		if entries++; entries > limits.entries {
+			return fmt.Errorf("invalid bundle: more than %d entries", limits.entries)
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid bundle: entry %q escapes the function directory", hdr.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if hdr.Size > limits.fileSize {
+				return fmt.Errorf("invalid bundle: %s is larger than %d bytes", hdr.Name, limits.fileSize)
+			}
+			if extracted+hdr.Size > limits.extracted {
+				return fmt.Errorf("invalid bundle: larger than %d bytes extracted", limits.extracted)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0755|0644)
+			if err != nil {
+				return err
+			}
+			n, err := io.Copy(f, io.LimitReader(tr, limits.fileSize))
+			f.Close()
+			extracted += n
+			if err != nil {
+				return err
+			}
+		default:
+			// symlinks and devices are dropped, the build only needs regular files
+		}
+	}
+}
+
+// validatebundle checks that an extracted bundle is a deployable function named name
+func ValidateBundle(dir, name string) (*Config, error) {
+	config, err := LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	if config.Name != name {
+		return nil, fmt.Errorf("nanolambda.yaml names function '%s', expected '%s'", config.Name, name)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Dockerfile")); err != nil {
+		// without a dockerfile the code must be runnable on the base image
+		if ok, err := IsBuildless(dir, config); !ok {
+			if err == nil {
+				err = fmt.Errorf("bundle has no Dockerfile")
+			}
+			return nil, err
+		}
+	}
+	return config, nil
+}
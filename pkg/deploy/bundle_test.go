@@ -0,0 +1,88 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gzipTar returns a bundle with one regular file per name, each holding size bytes
+func gzipTar(t *testing.T, size int, names ...string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(size)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(make([]byte, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestExtractBundleRejectsEscapes(t *testing.T) {
+	for _, name := range []string{"../escape.py", "lib/../../escape.py", "..", "/tmp/escape.py"} {
+		root := t.TempDir()
+		dir := filepath.Join(root, "function")
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		err := ExtractBundle(gzipTar(t, 1, "handler.py", name), dir)
+		if err == nil || !strings.Contains(err.Error(), "escapes the function directory") {
+			t.Errorf("%s: err = %v, want an escape error", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(root, "escape.py")); err == nil {
+			t.Errorf("%s was written outside the function directory", name)
+		}
+	}
+
+	// names that only look like a parent stay inside
+	dir := t.TempDir()
+	if err := ExtractBundle(gzipTar(t, 1, "..hidden.py", "lib/../handler.py"), dir); err != nil {
+		t.Fatalf("ExtractBundle: %v", err)
+	}
+	for _, name := range []string{"..hidden.py", "handler.py"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was not extracted: %v", name, err)
+		}
+	}
+}
+
+func TestExtractBundleLimits(t *testing.T) {
+	limits := bundleLimits{fileSize: 100, extracted: 250, entries: 3}
+	tests := []struct {
+		name    string
+		bundle  *bytes.Buffer
+		wantErr string
+	}{
+		{"within the limits", gzipTar(t, 80, "a", "b", "c"), ""},
+		{"file too large", gzipTar(t, 101, "a"), "larger than 100 bytes"},
+		{"too much in total", gzipTar(t, 90, "a", "b", "c"), "larger than 250 bytes extracted"},
+		{"too many entries", gzipTar(t, 1, "a", "b", "c", "d"), "more than 3 entries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := extractBundle(tt.bundle, t.TempDir(), limits)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("extractBundle: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/docker"
+	"github.com/nikhi/nanolambda/pkg/events"
+	"github.com/nikhi/nanolambda/pkg/registry"
+	"gopkg.in/yaml.v2"
+)
+
+// config is the contents of a function's nanolambda.yaml
+type Config struct {
+	Name    string `yaml:"name"`
+	Runtime string `yaml:"runtime"`
+	Timeout int    `yaml:"timeout"`
+
+	// memory limit of each container in mb, enforced by docker and used for capacity and billing
+	Memory int64 `yaml:"memory"`
+
+	// namespace groups functions in usage reports, "default" if empty
+	Namespace string `yaml:"namespace"`
+
+	// provisioned replicas kept warm at all times, the first matching schedule entry overrides it
+	MinInstances int                         `yaml:"min_instances"`
+	Schedule     []registry.ScheduleOverride `yaml:"schedule"`
+
+	// autoscaling, 0 uses the gateway defaults
+	TargetConcurrency int `yaml:"target_concurrency"`
+	MaxInstances      int `yaml:"max_instances"`
+
+	// recycle replicas after this many seconds or requests, 0 disables
+	MaxLifetime int `yaml:"max_lifetime"`
+	MaxRequests int `yaml:"max_requests"`
+
+	// readiness probe for new containers, e.g. a longer deadline for slow imports
+	Readiness *registry.ReadinessProbe `yaml:"readiness"`
+
+	// fraction of invocations traced (0 to 1), unset uses the gateway's TRACING_SAMPLE_RATE
+	TraceSampleRate *float64 `yaml:"trace_sample_rate"`
+}
+
+// memory limits in mb, docker refuses limits below 6 mb
+const (
+	DefaultMemoryMB = 128
+	MinMemoryMB     = 6
+)
+
+// loadconfig reads and validates nanolambda.yaml from a function directory
+func LoadConfig(dir string) (*Config, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "nanolambda.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading nanolambda.yaml: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing nanolambda.yaml: %w", err)
+	}
+	if config.Name == "" {
+		return nil, fmt.Errorf("nanolambda.yaml: name is required")
+	}
+	if config.Memory == 0 {
+		config.Memory = DefaultMemoryMB
+	}
+	if config.Memory < MinMemoryMB {
+		return nil, fmt.Errorf("nanolambda.yaml: memory must be at least %d (mb)", MinMemoryMB)
+	}
+	if config.MinInstances < 0 {
+		return nil, fmt.Errorf("nanolambda.yaml: min_instances can't be negative")
+	}
+	if config.TargetConcurrency < 0 || config.MaxInstances < 0 {
+		return nil, fmt.Errorf("nanolambda.yaml: target_concurrency and max_instances can't be negative")
+	}
+	if config.MaxLifetime < 0 || config.MaxRequests < 0 {
+		return nil, fmt.Errorf("nanolambda.yaml: max_lifetime and max_requests can't be negative")
+	}
+	if r := config.TraceSampleRate; r != nil && (*r < 0 || *r > 1) {
+		return nil, fmt.Errorf("nanolambda.yaml: trace_sample_rate must be between 0 and 1")
+	}
+	if config.Readiness != nil {
+		if err := config.Readiness.Validate(); err != nil {
+			return nil, fmt.Errorf("nanolambda.yaml: readiness: %w", err)
+		}
+	}
+	for i, o := range config.Schedule {
+		if err := o.Validate(); err != nil {
+			return nil, fmt.Errorf("nanolambda.yaml: schedule entry %d: %w", i, err)
+		}
+	}
+	return &config, nil
+}
+
+// deployer builds function images and registers them
+type Deployer struct {
+	Docker    *docker.Manager
+	Registry  *registry.Manager
+	Artifacts *ArtifactStore // code store for buildless functions
+	Events    *events.Bus    // deploy started and finished events, optional
+
+	mu     sync.Mutex
+	active map[string]*Build // builds still in progress, by deployment id
+}
+
+// build is a deployment whose image is still being built
+type Build struct {
+	logs syncBuffer
+	done chan struct{}
+}
+
+// logs returns the build output written after offset and the new offset
+func (b *Build) Logs(offset int) (string, int) {
+	return b.logs.From(offset)
+}
+
+// done is closed once the deployment has finished, successfully or not
+func (b *Build) Done() <-chan struct{} {
+	return b.done
+}
+
+// deploy builds the function in dir and registers the resulting image as a new version
+// build output is streamed to out and stored with the deployment record
+func (d *Deployer) Deploy(ctx context.Context, dir string, out io.Writer) (*registry.Deployment, error) {
+	config, err := LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	dep, err := d.begin(config, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Build{done: make(chan struct{})}
+	err = d.build(ctx, config, dir, dep, b, out)
+	return dep, err
+}
+
+// start records a deployment and builds it in the background
+// cleanup, if set, runs once the build has finished (e.g. to remove an extracted bundle)
+func (d *Deployer) Start(config *Config, dir string, cleanup func()) (*registry.Deployment, error) {
+	dep, err := d.begin(config, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Build{done: make(chan struct{})}
+	d.mu.Lock()
+	if d.active == nil {
+		d.active = make(map[string]*Build)
+	}
+	d.active[dep.ID] = b
+	d.mu.Unlock()
+
+	// copy so callers can read the returned record while the build updates its own
+	result := *dep
+	go func() {
+		// builds outlive the upload request, so they get their own context
+		d.build(context.Background(), config, dir, dep, b, nil)
+
+		d.mu.Lock()
+		delete(d.active, dep.ID)
+		d.mu.Unlock()
+		if cleanup != nil {
+			cleanup()
+		}
+	}()
+
+	return &result, nil
+}
+
+// active returns the in-progress build for a deployment, if there is one
+func (d *Deployer) Active(id string) (*Build, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	b, ok := d.active[id]
+	return b, ok
+}
+
+func (d *Deployer) begin(config *Config, dir string) (*registry.Deployment, error) {
+	buildless, err := IsBuildless(dir, config)
+	if err != nil {
+		return nil, err
+	}
+
+	dep := &registry.Deployment{
+		ID:        registry.NewDeploymentID(),
+		Function:  config.Name,
+		Status:    registry.DeploymentBuilding,
+		ImageTag:  fmt.Sprintf("nanolambda/%s:latest", config.Name),
+		CreatedAt: time.Now(),
+	}
+	if buildless && d.Artifacts != nil {
+		dep.ImageTag = BaseImage
+	}
+	if err := d.Registry.CreateDeployment(*dep); err != nil {
+		return nil, fmt.Errorf("error recording deployment: %w", err)
+	}
+	d.Events.Publish(events.Event{Type: events.DeployStarted, Function: dep.Function, Deployment: dep.ID})
+	return dep, nil
+}
+
+func (d *Deployer) build(ctx context.Context, config *Config, dir string, dep *registry.Deployment, b *Build, out io.Writer) error {
+	defer close(b.done)
+
+	w := io.Writer(&b.logs)
+	if out != nil {
+		w = io.MultiWriter(&b.logs, out)
+	}
+
+	fn := registry.Function{
+		Name:        config.Name,
+		Runtime:     config.Runtime,
+		ImageTag:    dep.ImageTag,
+		CreatedAt:   time.Now(),
+		MemoryLimit: config.Memory,
+		Timeout:     config.Timeout,
+
+		MinInstances: config.MinInstances,
+		Schedule:     config.Schedule,
+
+		TargetConcurrency: config.TargetConcurrency,
+		MaxInstances:      config.MaxInstances,
+
+		MaxLifetime: config.MaxLifetime,
+		MaxRequests: config.MaxRequests,
+		Readiness:   config.Readiness,
+		Namespace:   config.Namespace,
+
+		TraceSampleRate: config.TraceSampleRate,
+	}
+
+	var err error
+	if dep.ImageTag == BaseImage {
+		// buildless: store the code and run it on the shared base image
+		var size int64
+		fn.Artifact, size, err = d.Artifacts.Put(dir)
+		dep.Artifact = fn.Artifact
+		if err == nil {
+			fmt.Fprintf(w, "No custom Dockerfile, skipping image build\n")
+			fmt.Fprintf(w, "Stored code artifact %s (%d bytes), runs on %s\n", fn.Artifact, size, BaseImage)
+		}
+	} else {
+		fn.ImageDigest, err = d.Docker.BuildImage(ctx, dir, dep.ImageTag, w)
+		dep.ImageDigest = fn.ImageDigest
+	}
+
+	if err == nil {
+		dep.Version, err = d.Registry.PublishVersion(fn, dep.ID)
+		if err != nil {
+			err = fmt.Errorf("error registering function: %w", err)
+		}
+	}
+
+	dep.Status = registry.DeploymentSucceeded
+	if err != nil {
+		dep.Status = registry.DeploymentFailed
+		dep.Error = err.Error()
+		fmt.Fprintf(w, "deployment failed: %v\n", err)
+	}
+	dep.Logs = b.logs.String()
+	finished := time.Now()
+	dep.FinishedAt = &finished
+
+	if ferr := d.Registry.FinishDeployment(*dep); ferr != nil && err == nil {
+		err = fmt.Errorf("error recording deployment: %w", ferr)
+	}
+	d.Events.Publish(events.Event{
+		Type:       events.DeployFinished,
+		Function:   dep.Function,
+		Deployment: dep.ID,
+		Version:    dep.Version,
+		Status:     dep.Status,
+		DurationMS: float64(finished.Sub(dep.CreatedAt)) / float64(time.Millisecond),
+		Error:      dep.Error,
+	})
+	return err
+}
+
+// syncbuffer is a bytes.Buffer that is safe to read while a build is writing to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// from returns everything written after offset and the new end offset
+func (b *syncBuffer) From(offset int) (string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data := b.buf.Bytes()
+	if offset > len(data) {
+		offset = len(data)
+	}
+	return string(data[offset:]), len(data)
+}
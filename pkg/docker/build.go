@@ -0,0 +1,211 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/moby/patternmatcher"
+)
+
+// buildimage builds an image from a function directory through the docker api
+// build output is written to out as it arrives
+// returns the content-addressed image id (sha256:...) of the result
+func (m *Manager) BuildImage(ctx context.Context, contextDir, tag string, out io.Writer) (string, error) {
+	// tar the context in the background so large directories stream straight to the daemon
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(TarContext(contextDir, pw))
+	}()
+	defer pr.Close()
+
+	resp, err := m.cli.ImageBuild(ctx, pr, types.ImageBuildOptions{
+		Tags:        []string{tag},
+		Dockerfile:  "Dockerfile",
+		Remove:      true,
+		ForceRemove: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start build: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var imageID string
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("failed to read build output: %w", err)
+		}
+
+		if msg.Error != nil {
+			return "", fmt.Errorf("build failed: %s", msg.Error.Message)
+		}
+
+		// the final image id arrives as an aux message: {"ID": "sha256:..."}
+		if msg.Aux != nil {
+			var aux struct {
+				ID string `json:"ID"`
+			}
+			if err := json.Unmarshal(*msg.Aux, &aux); err == nil && aux.ID != "" {
+				imageID = aux.ID
+			}
+		}
+
+		if out == nil {
+			continue
+		}
+		switch {
+		case msg.Stream != "":
+			fmt.Fprint(out, msg.Stream)
+		case msg.Status != "":
+			if msg.ID != "" {
+				fmt.Fprintf(out, "%s: %s\n", msg.ID, msg.Status)
+			} else {
+				fmt.Fprintln(out, msg.Status)
+			}
+		}
+	}
+
+	if imageID == "" {
+		// older daemons don't send aux messages, fall back to inspecting the tag
+		inspect, _, err := m.cli.ImageInspectWithRaw(ctx, tag)
+		if err != nil {
+			return "", fmt.Errorf("failed to inspect built image: %w", err)
+		}
+		imageID = inspect.ID
+	}
+
+	return imageID, nil
+}
+
+// tarcontext writes dir as an uncompressed tar stream to w
+// files matched by the directory's .dockerignore are skipped
+func TarContext(dir string, w io.Writer) error {
+	return tarDir(dir, w, false)
+}
+
+// tarcode is like tarcontext but produces a reproducible stream:
+// timestamps and ownership are cleared so identical code always hashes the same
+func TarCode(dir string, w io.Writer) error {
+	return tarDir(dir, w, true)
+}
+
+func tarDir(dir string, w io.Writer, normalize bool) error {
+	excludes, err := readDockerignore(dir)
+	if err != nil {
+		return err
+	}
+	pm, err := patternmatcher.New(excludes)
+	if err != nil {
+		return fmt.Errorf("invalid .dockerignore: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		// the dockerfile and .dockerignore are always sent, like the docker cli does
+		if rel != "Dockerfile" && rel != ".dockerignore" {
+			skip, err := pm.MatchesOrParentMatches(rel)
+			if err != nil {
+				return err
+			}
+			if skip {
+				if info.IsDir() && !pm.Exclusions() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if normalize {
+			hdr.ModTime = time.Unix(0, 0)
+			hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+			hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to tar build context: %w", err)
+	}
+	return tw.Close()
+}
+
+// readdockerignore returns the exclude patterns from dir/.dockerignore, if any
+func readDockerignore(dir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// patterns are relative to the context root, like the docker cli treats them
+		neg := strings.HasPrefix(line, "!")
+		line = filepath.ToSlash(filepath.Clean(strings.TrimPrefix(strings.TrimPrefix(line, "!"), "/")))
+		if neg {
+			line = "!" + line
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestTarContextDockerignore(t *testing.T) {
+	tests := []struct {
+		name   string
+		ignore string
+		want   []string
+	}{
+		{
+			name: "no dockerignore",
+			want: []string{"Dockerfile", "handler.py", "lib/", "lib/util.py", "node_modules/", "node_modules/dep.js", "secret.env"},
+		},
+		{
+			name:   "excluded directory is skipped whole",
+			ignore: "node_modules\n",
+			want:   []string{".dockerignore", "Dockerfile", "handler.py", "lib/", "lib/util.py", "secret.env"},
+		},
+		{
+			name:   "comments and blank lines",
+			ignore: "# local settings\n\n*.env\n",
+			want:   []string{".dockerignore", "Dockerfile", "handler.py", "lib/", "lib/util.py", "node_modules/", "node_modules/dep.js"},
+		},
+		{
+			name:   "exception inside an excluded directory",
+			ignore: "lib\n!lib/util.py\n",
+			want:   []string{".dockerignore", "Dockerfile", "handler.py", "lib/util.py", "node_modules/", "node_modules/dep.js", "secret.env"},
+		},
+		{
+			name:   "dockerfile and dockerignore are always sent",
+			ignore: "*\n",
+			want:   []string{".dockerignore", "Dockerfile"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := map[string]string{
+				"Dockerfile":          "FROM python:3.11-slim\n",
+				"handler.py":          "def handle(event): return event\n",
+				"lib/util.py":         "",
+				"node_modules/dep.js": "",
+				"secret.env":          "TOKEN=x\n",
+			}
+			if tt.ignore != "" {
+				files[".dockerignore"] = tt.ignore
+			}
+			for name, content := range files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := TarContext(dir, &buf); err != nil {
+				t.Fatalf("TarContext: %v", err)
+			}
+			var got []string
+			tr := tar.NewReader(&buf)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, hdr.Name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entries = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTarCodeIsReproducible(t *testing.T) {
+	dir := t.TempDir()
+	handler := filepath.Join(dir, "handler.py")
+	if err := os.WriteFile(handler, []byte("def handle(event): return event\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after bytes.Buffer
+	if err := TarCode(dir, &before); err != nil {
+		t.Fatal(err)
+	}
+	// touching the file must not change the digest of unchanged code
+	stamp := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(handler, stamp, stamp); err != nil {
+		t.Fatal(err)
+	}
+	if err := TarCode(dir, &after); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before.Bytes(), after.Bytes()) {
+		t.Error("TarCode output changed with the file's timestamp")
+	}
+}
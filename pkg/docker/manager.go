@@ -11,8 +11,15 @@ import (
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
+	"github.com/nikhi/nanolambda/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracer records a span for each phase of a container start
+var tracer = otel.Tracer("github.com/nikhi/nanolambda/pkg/docker")
+
 // manager handles docker container operations
 type Manager struct {
 	cli *client.Client
@@ -27,22 +34,57 @@ func NewManager() (*Manager, error) {
 	return &Manager{cli: cli}, nil
 }
 
+// containerspec describes a function container to start
+type ContainerSpec struct {
+	Image string // image tag or id to run
+	Name  string // function name, used in the container name
+	// Code, if set, is a tar of the function directory copied to /function before start
+	// this lets buildless functions run on a shared base image
+	Code io.Reader
+	// MemoryMB is the container's memory limit in mb, without swap; 0 is unlimited
+	MemoryMB int64
+}
+
+// startphases is how long each step of a container start took
+type StartPhases struct {
+	Pull   time.Duration // zero if the image was already present
+	Create time.Duration // create and copy the function code in
+	Start  time.Duration // start and look up the assigned port
+}
+
 // startcontainer starts a container for a given function image
 // returns the container ip and id
-func (m *Manager) StartContainer(ctx context.Context, imageTag string, name string) (string, string, error) {
+func (m *Manager) StartContainer(ctx context.Context, spec ContainerSpec) (string, string, error) {
+	addr, id, _, err := m.StartContainerTimed(ctx, spec)
+	return addr, id, err
+}
+
+// startcontainertimed is startcontainer that also reports how long each phase took
+func (m *Manager) StartContainerTimed(ctx context.Context, spec ContainerSpec) (string, string, StartPhases, error) {
+	imageTag, name := spec.Image, spec.Name
+	var phases StartPhases
+
 	// check if image exists locally
+	phaseStart := time.Now()
+	_, span := tracer.Start(ctx, "docker.pull", trace.WithAttributes(attribute.String("image", imageTag)))
 	_, _, err := m.cli.ImageInspectWithRaw(ctx, imageTag)
+	span.SetAttributes(attribute.Bool("pulled", client.IsErrNotFound(err)))
 	if client.IsErrNotFound(err) {
 		// attempt to pull (assuming it's in a registry, though for this local demo it might be built locally)
 		// for local dev without a registry, we skip pull if it's not found and fail.
 		// in a real scenario, we'd pull:
 		reader, err := m.cli.ImagePull(ctx, imageTag, types.ImagePullOptions{})
 		if err != nil {
-			return "", "", fmt.Errorf("failed to pull image %s: %w", imageTag, err)
+			observe("pull", phaseStart, err)
+			tracing.End(span, err)
+			return "", "", phases, fmt.Errorf("failed to pull image %s: %w", imageTag, err)
 		}
 		defer reader.Close()
-		io.Copy(io.Discard, reader) // wait for pull to finish
+		_, err = io.Copy(io.Discard, reader) // wait for pull to finish
+		observe("pull", phaseStart, err)
+		phases.Pull = time.Since(phaseStart)
 	}
+	span.End()
 
 	// create container
 	// we bind to a random host port to avoid conflicts, or use internal network
@@ -64,41 +106,120 @@ func (m *Manager) StartContainer(ctx context.Context, imageTag string, name stri
 			},
 		},
 		AutoRemove: true, // clean up after stop
+		Resources:  memoryLimit(spec.MemoryMB),
 	}
 
 	networkConfig := &network.NetworkingConfig{}
 
-	resp, err := m.cli.ContainerCreate(ctx, config, hostConfig, networkConfig, nil, "nanolambda-"+name+"-"+fmt.Sprintf("%d", time.Now().UnixNano()))
+	phaseStart = time.Now()
+	_, span = tracer.Start(ctx, "docker.create")
+	resp, err := m.cli.ContainerCreate(ctx, config, hostConfig, networkConfig, nil, ContainerName(name))
+	observe("create", phaseStart, err)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create container: %w", err)
+		tracing.End(span, err)
+		return "", "", phases, fmt.Errorf("failed to create container: %w", err)
 	}
 
+	// copy the function code in before start so the runner finds it on boot
+	if spec.Code != nil {
+		copyStart := time.Now()
+		err := m.cli.CopyToContainer(ctx, resp.ID, "/function", spec.Code, types.CopyToContainerOptions{})
+		observe("copy", copyStart, err)
+		if err != nil {
+			m.cli.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
+			tracing.End(span, err)
+			return "", "", phases, fmt.Errorf("failed to copy function code: %w", err)
+		}
+	}
+	phases.Create = time.Since(phaseStart)
+	span.SetAttributes(attribute.String("container.id", resp.ID))
+	span.End()
+
 	// start container
-	if err := m.cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		return "", "", fmt.Errorf("failed to start container: %w", err)
+	phaseStart = time.Now()
+	_, span = tracer.Start(ctx, "docker.start")
+	err = m.cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{})
+	observe("start", phaseStart, err)
+	tracing.End(span, err)
+	if err != nil {
+		return "", "", phases, fmt.Errorf("failed to start container: %w", err)
 	}
 
 	// inspect to get the assigned port
+	inspectStart := time.Now()
+	_, span = tracer.Start(ctx, "docker.inspect")
 	inspect, err := m.cli.ContainerInspect(ctx, resp.ID)
+	observe("inspect", inspectStart, err)
+	tracing.End(span, err)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to inspect container: %w", err)
+		return "", "", phases, fmt.Errorf("failed to inspect container: %w", err)
 	}
 
 	// get the host port that maps to 8080
 	ports := inspect.NetworkSettings.Ports["8080/tcp"]
 	if len(ports) == 0 {
-		return "", "", fmt.Errorf("no ports bound")
+		return "", "", phases, fmt.Errorf("no ports bound")
 	}
 	
 	hostPort := ports[0].HostPort
 	hostIP := "127.0.0.1" // localhost for this architecture
 
-	return hostIP + ":" + hostPort, resp.ID, nil
+	phases.Start = time.Since(phaseStart)
+
+	return hostIP + ":" + hostPort, resp.ID, phases, nil
 }
 
 // stopcontainer kills a container
 func (m *Manager) StopContainer(ctx context.Context, containerID string) error {
-	return m.cli.ContainerStop(ctx, containerID, container.StopOptions{})
+	start := time.Now()
+	err := m.cli.ContainerStop(ctx, containerID, container.StopOptions{})
+	observe("stop", start, err)
+	return err
+}
+
+// pausecontainer freezes a container's processes, freeing cpu while keeping it warm
+func (m *Manager) PauseContainer(ctx context.Context, containerID string) error {
+	start := time.Now()
+	err := m.cli.ContainerPause(ctx, containerID)
+	observe("pause", start, err)
+	return err
+}
+
+// unpausecontainer resumes a paused container
+func (m *Manager) UnpauseContainer(ctx context.Context, containerID string) error {
+	start := time.Now()
+	err := m.cli.ContainerUnpause(ctx, containerID)
+	observe("unpause", start, err)
+	return err
+}
+
+// renamecontainer gives a container a new name, e.g. when a pooled runner is assigned to a function
+func (m *Manager) RenameContainer(ctx context.Context, containerID, name string) error {
+	start := time.Now()
+	err := m.cli.ContainerRename(ctx, containerID, name)
+	observe("rename", start, err)
+	return err
+}
+
+// setmemorylimit changes a running container's memory limit, e.g. when a pooled runner is assigned to a function
+func (m *Manager) SetMemoryLimit(ctx context.Context, containerID string, memoryMB int64) error {
+	start := time.Now()
+	_, err := m.cli.ContainerUpdate(ctx, containerID, container.UpdateConfig{Resources: memoryLimit(memoryMB)})
+	observe("update", start, err)
+	return err
+}
+
+// memorylimit caps a container's memory at memoryMB with no extra swap, 0 is unlimited
+func memoryLimit(memoryMB int64) container.Resources {
+	if memoryMB <= 0 {
+		return container.Resources{}
+	}
+	return container.Resources{Memory: memoryMB << 20, MemorySwap: memoryMB << 20}
+}
+
+// containername returns a unique container name for a function
+func ContainerName(function string) string {
+	return "nanolambda-" + function + "-" + fmt.Sprintf("%d", time.Now().UnixNano())
 }
 
 // listrunningcontainers returns a list of containers managed by nanolambda
@@ -0,0 +1,37 @@
+package docker
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	apiDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "docker_api_duration_seconds",
+			Help:    "Latency of docker api calls made by the gateway, by operation",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"operation"},
+	)
+	apiErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "docker_api_errors_total",
+			Help: "Failed docker api calls, by operation",
+		},
+		[]string{"operation"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(apiDuration, apiErrors)
+}
+
+// observe records the latency and outcome of one docker api call
+func observe(operation string, start time.Time, err error) {
+	apiDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		apiErrors.WithLabelValues(operation).Inc()
+	}
+}
@@ -0,0 +1,315 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/nikhi/nanolambda/pkg/logging"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cpuSeconds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "function_cpu_seconds_total",
+			Help: "CPU time used by a function's containers",
+		},
+		[]string{"function"},
+	)
+	cpuThrottled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "function_cpu_throttled_seconds_total",
+			Help: "Time a function's containers were throttled by their cpu limit",
+		},
+		[]string{"function"},
+	)
+	memoryWorkingSet = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "function_memory_working_set_bytes",
+			Help: "Largest current memory working set among a function's containers",
+		},
+		[]string{"function"},
+	)
+	memoryPeak = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "function_memory_peak_bytes",
+			Help: "Peak memory working set of any of a function's containers since gateway start",
+		},
+		[]string{"function"},
+	)
+	networkBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "function_network_bytes_total",
+			Help: "Network traffic of a function's containers, by direction (rx, tx)",
+		},
+		[]string{"function", "direction"},
+	)
+	memorySizing = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "function_memory_sizing",
+			Help: "1 if a function's peak memory is far below (oversized) or close to (near_limit) its configured limit",
+		},
+		[]string{"function", "flag"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cpuSeconds, cpuThrottled, memoryWorkingSet, memoryPeak, networkBytes, memorySizing)
+}
+
+// memory sizing flags
+const (
+	SizingOK        = "ok"
+	SizingOversized = "oversized"  // peak below OversizedRatio of the limit
+	SizingNearLimit = "near_limit" // peak above NearLimitRatio of the limit
+)
+
+// thresholds for the memory sizing flags, as a fraction of the configured limit
+const (
+	OversizedRatio = 0.25
+	NearLimitRatio = 0.9
+)
+
+// statstarget is a container whose resource usage should be collected
+type StatsTarget struct {
+	ID          string
+	Function    string
+	MemoryLimit int64 // configured limit in mb, 0 if unknown
+}
+
+// usage is the resource usage of one function seen by the collector
+type Usage struct {
+	WorkingSetBytes uint64  `json:"working_set_bytes"`
+	PeakBytes       uint64  `json:"peak_bytes"`
+	MemoryLimitMB   int64   `json:"memory_limit_mb"`
+	CPUSeconds      float64 `json:"cpu_seconds"`
+	Sizing          string  `json:"sizing"`
+}
+
+// statscollector streams docker stats for the containers it is given
+type StatsCollector struct {
+	docker *Manager
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	streams map[string]*statsStream      // container id -> stream
+	current map[string]map[string]uint64 // function -> container id -> working set
+	usage   map[string]*Usage            // function -> totals
+}
+
+// statsstream is the open stats stream of one container
+type statsStream struct {
+	cancel context.CancelFunc
+}
+
+// newstatscollector creates a collector that reads stats through m
+func (m *Manager) NewStatsCollector() *StatsCollector {
+	return &StatsCollector{
+		docker:  m,
+		logger:  logging.Component("docker"),
+		streams: make(map[string]*statsStream),
+		current: make(map[string]map[string]uint64),
+		usage:   make(map[string]*Usage),
+	}
+}
+
+// run keeps one stats stream open per target until ctx is done
+// targets is polled every interval for containers that started or stopped
+func (c *StatsCollector) Run(ctx context.Context, interval time.Duration, targets func() []StatsTarget) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.sync(ctx, targets())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync opens streams for new targets and closes the ones no longer tracked
+func (c *StatsCollector) sync(ctx context.Context, targets []StatsTarget) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		seen[t.ID] = true
+		if _, ok := c.streams[t.ID]; ok {
+			continue
+		}
+		streamCtx, cancel := context.WithCancel(ctx)
+		s := &statsStream{cancel: cancel}
+		c.streams[t.ID] = s
+		go c.stream(streamCtx, t, s)
+	}
+	for id, s := range c.streams {
+		if !seen[id] {
+			s.cancel()
+			delete(c.streams, id)
+		}
+	}
+}
+
+// stream reads a container's stats until it stops or the stream is cancelled
+func (c *StatsCollector) stream(ctx context.Context, t StatsTarget, s *statsStream) {
+	defer c.forget(t, s)
+
+	resp, err := c.docker.cli.ContainerStats(ctx, t.ID, true)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	var prev *types.StatsJSON
+	for {
+		var s types.StatsJSON
+		if err := dec.Decode(&s); err != nil {
+			return
+		}
+		c.record(t, prev, &s)
+		prev = &s
+	}
+}
+
+// record turns one stats sample into metrics, counters advance by the delta to the previous sample
+func (c *StatsCollector) record(t StatsTarget, prev, s *types.StatsJSON) {
+	fn := t.Function
+	workingSet := workingSet(s.MemoryStats)
+
+	var rx, tx uint64
+	for _, n := range s.Networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+
+	if prev != nil {
+		if d := delta(s.CPUStats.CPUUsage.TotalUsage, prev.CPUStats.CPUUsage.TotalUsage); d > 0 {
+			cpuSeconds.WithLabelValues(fn).Add(float64(d) / 1e9)
+		}
+		if d := delta(s.CPUStats.ThrottlingData.ThrottledTime, prev.CPUStats.ThrottlingData.ThrottledTime); d > 0 {
+			cpuThrottled.WithLabelValues(fn).Add(float64(d) / 1e9)
+		}
+		var prevRx, prevTx uint64
+		for _, n := range prev.Networks {
+			prevRx += n.RxBytes
+			prevTx += n.TxBytes
+		}
+		networkBytes.WithLabelValues(fn, "rx").Add(float64(delta(rx, prevRx)))
+		networkBytes.WithLabelValues(fn, "tx").Add(float64(delta(tx, prevTx)))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current[fn] == nil {
+		c.current[fn] = make(map[string]uint64)
+	}
+	c.current[fn][t.ID] = workingSet
+
+	u, ok := c.usage[fn]
+	if !ok {
+		u = &Usage{}
+		c.usage[fn] = u
+	}
+	u.MemoryLimitMB = t.MemoryLimit
+	if prev != nil {
+		u.CPUSeconds += float64(delta(s.CPUStats.CPUUsage.TotalUsage, prev.CPUStats.CPUUsage.TotalUsage)) / 1e9
+	}
+	u.WorkingSetBytes = 0
+	for _, ws := range c.current[fn] {
+		u.WorkingSetBytes = max(u.WorkingSetBytes, ws)
+	}
+	u.PeakBytes = max(u.PeakBytes, workingSet)
+
+	memoryWorkingSet.WithLabelValues(fn).Set(float64(u.WorkingSetBytes))
+	memoryPeak.WithLabelValues(fn).Set(float64(u.PeakBytes))
+	c.flag(fn, u)
+}
+
+// flag updates the memory sizing flag of a function
+// callers must hold c.mu
+func (c *StatsCollector) flag(fn string, u *Usage) {
+	sizing := SizingOK
+	if u.MemoryLimitMB > 0 {
+		ratio := float64(u.PeakBytes) / float64(u.MemoryLimitMB<<20)
+		switch {
+		case ratio >= NearLimitRatio:
+			sizing = SizingNearLimit
+		case ratio < OversizedRatio:
+			sizing = SizingOversized
+		}
+	}
+	if sizing != u.Sizing && sizing != SizingOK {
+		c.logger.Warn("memory limit sized poorly", "function", fn, "peak_mb", u.PeakBytes>>20, "limit_mb", u.MemoryLimitMB, "sizing", sizing)
+	}
+	u.Sizing = sizing
+
+	for _, f := range []string{SizingOversized, SizingNearLimit} {
+		v := 0.0
+		if f == sizing {
+			v = 1
+		}
+		memorySizing.WithLabelValues(fn, f).Set(v)
+	}
+}
+
+// forget drops a stopped container from the current working sets
+// and closes its stream, so the next sync opens a new one if the container is still tracked
+func (c *StatsCollector) forget(t StatsTarget, s *statsStream) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s.cancel()
+	if c.streams[t.ID] == s {
+		delete(c.streams, t.ID)
+	}
+
+	delete(c.current[t.Function], t.ID)
+	if u, ok := c.usage[t.Function]; ok {
+		u.WorkingSetBytes = 0
+		for _, ws := range c.current[t.Function] {
+			u.WorkingSetBytes = max(u.WorkingSetBytes, ws)
+		}
+		memoryWorkingSet.WithLabelValues(t.Function).Set(float64(u.WorkingSetBytes))
+	}
+}
+
+// usage returns what the collector has seen per function since gateway start
+func (c *StatsCollector) Usage() map[string]Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[string]Usage, len(c.usage))
+	for fn, u := range c.usage {
+		out[fn] = *u
+	}
+	return out
+}
+
+// workingset is memory usage minus reclaimable page cache, as cadvisor and kubectl top report it
+func workingSet(m types.MemoryStats) uint64 {
+	inactive := m.Stats["inactive_file"] // cgroup v2
+	if v, ok := m.Stats["total_inactive_file"]; ok {
+		inactive = v // cgroup v1
+	}
+	if inactive > m.Usage {
+		return 0
+	}
+	return m.Usage - inactive
+}
+
+// delta returns cur-prev, or 0 if the counter went backwards
+func delta(cur, prev uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// event types
+const (
+	ColdStartBegin      = "cold_start.begin"     // a container is being started for a function
+	ColdStartEnd        = "cold_start.end"       // it is ready, or Error says why not
+	WarmupTriggered     = "warmup.triggered"     // the warmup api, the predictor or a policy pre-warm asked for replicas
+	ContainerRegistered = "container.registered" // a ready container joined a function's replicas
+	ContainerReaped     = "container.reaped"     // a container was stopped, Reason says why
+	ContainerCrashed    = "container.crashed"    // a container failed its health checks and was removed
+	DeployStarted       = "deploy.started"
+	DeployFinished      = "deploy.finished" // Status is succeeded or failed
+	BreakerOpened       = "breaker.opened"
+	BreakerClosed       = "breaker.closed"
+)
+
+var (
+	droppedEvents = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "events_dropped_total",
+			Help: "Lifecycle events not delivered to a subscriber that wasn't keeping up",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(droppedEvents)
+}
+
+// event is one lifecycle event, fields that don't apply to its type are left empty
+type Event struct {
+	ID         uint64    `json:"id"`
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Function   string    `json:"function,omitempty"`
+	Version    int       `json:"version,omitempty"`
+	Container  string    `json:"container,omitempty"` // short container id
+	Origin     string    `json:"origin,omitempty"`    // why a container was started, see the reaper origins
+	Reason     string    `json:"reason,omitempty"`
+	Replicas   int       `json:"replicas,omitempty"`
+	Deployment string    `json:"deployment,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	DurationMS float64   `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// subscriberBuffer is how many events a subscriber can fall behind before events are dropped for it
+const subscriberBuffer = 256
+
+// bus fans events out to subscribers and keeps the most recent ones for new subscribers
+// a nil bus discards everything, so publishers don't need to check
+type Bus struct {
+	mu     sync.Mutex
+	ring   []Event
+	start  int // index of the oldest event in ring once it is full
+	size   int
+	nextID uint64
+	subs   map[chan Event]struct{}
+}
+
+// newbus creates a bus that remembers the last size events
+func NewBus(size int) *Bus {
+	return &Bus{
+		size: size,
+		subs: make(map[chan Event]struct{}),
+	}
+}
+
+// publish stamps an event with the next id and the current time and delivers it
+// subscribers that are behind miss it rather than slowing the publisher down
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+	e.Time = time.Now()
+
+	if len(b.ring) < b.size {
+		b.ring = append(b.ring, e)
+	} else if b.size > 0 {
+		b.ring[b.start] = e
+		b.start = (b.start + 1) % b.size
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			droppedEvents.Inc()
+		}
+	}
+}
+
+// subscribe returns the remembered events after the given id, oldest first, and a channel
+// of the events published from then on; an id from before a gateway restart returns everything
+// cancel must be called once the subscriber is done
+func (b *Bus) Subscribe(after uint64) ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if after > b.nextID {
+		after = 0
+	}
+	var recent []Event
+	for i := range b.ring {
+		e := b.ring[(b.start+i)%len(b.ring)]
+		if e.ID > after {
+			recent = append(recent, e)
+		}
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	b.subs[ch] = struct{}{}
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, ch)
+	}
+	return recent, ch, cancel
+}
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+)
+
+func publish(b *Bus, n int) {
+	for range n {
+		b.Publish(Event{Type: ContainerRegistered})
+	}
+}
+
+func ids(events []Event) []uint64 {
+	out := []uint64{}
+	for _, e := range events {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestSubscribeReplaysTheRing(t *testing.T) {
+	b := NewBus(4)
+	publish(b, 10)
+
+	recent, _, cancel := b.Subscribe(0)
+	defer cancel()
+	if got := ids(recent); !reflect.DeepEqual(got, []uint64{7, 8, 9, 10}) {
+		t.Errorf("new subscriber got %v, want the newest 4", got)
+	}
+}
+
+func TestSubscribeAfterLastEventID(t *testing.T) {
+	b := NewBus(4)
+	publish(b, 10)
+
+	recent, _, cancel := b.Subscribe(8)
+	defer cancel()
+	if got := ids(recent); !reflect.DeepEqual(got, []uint64{9, 10}) {
+		t.Errorf("Subscribe(8) = %v, want [9 10]", got)
+	}
+
+	// ids from before a gateway restart are ahead of the new ones
+	recent, _, cancel = b.Subscribe(99)
+	defer cancel()
+	if got := ids(recent); !reflect.DeepEqual(got, []uint64{7, 8, 9, 10}) {
+		t.Errorf("Subscribe(99) = %v, want the whole ring", got)
+	}
+}
+
+func TestLiveEvents(t *testing.T) {
+	b := NewBus(4)
+	_, ch, cancel := b.Subscribe(0)
+
+	b.Publish(Event{Type: DeployFinished, Function: "fn"})
+	if e := <-ch; e.ID != 1 || e.Type != DeployFinished || e.Function != "fn" || e.Time.IsZero() {
+		t.Errorf("live event = %+v", e)
+	}
+
+	cancel()
+	b.Publish(Event{Type: DeployStarted})
+	select {
+	case e := <-ch:
+		t.Errorf("cancelled subscriber got %+v", e)
+	default:
+	}
+}
+
+func TestPublishDoesntWaitForSlowSubscribers(t *testing.T) {
+	b := NewBus(1)
+	_, ch, cancel := b.Subscribe(0)
+	defer cancel()
+
+	publish(b, subscriberBuffer+10)
+	if len(ch) != subscriberBuffer {
+		t.Errorf("%d events buffered, want %d", len(ch), subscriberBuffer)
+	}
+}
@@ -0,0 +1,141 @@
+package history
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/logging"
+	"github.com/nikhi/nanolambda/pkg/registry"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	droppedInvocations = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "history_dropped_invocations_total",
+			Help: "Invocations not recorded in the history because the write queue was full",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(droppedInvocations)
+}
+
+// config tunes what the history keeps and for how long
+type Config struct {
+	SampleRate   float64       // fraction of successful invocations whose bodies are kept, errors always are
+	MaxBodyBytes int           // bodies are cut to this size
+	Redact       []string      // json fields whose values are replaced in stored bodies, matched case-insensitively
+	Retention    time.Duration // invocations older than this are pruned, 0 keeps everything
+}
+
+// defaultconfig keeps 1% of payloads up to 64 KiB for 7 days and redacts common secret fields
+func DefaultConfig() Config {
+	return Config{
+		SampleRate:   0.01,
+		MaxBodyBytes: 64 << 10,
+		Redact:       []string{"password", "secret", "token", "authorization", "api_key"},
+		Retention:    7 * 24 * time.Hour,
+	}
+}
+
+// queueSize is how many invocations can wait to be written before new ones are dropped
+const queueSize = 1024
+
+// recorder writes invocations to the registry in the background
+type Recorder struct {
+	config   Config
+	registry *registry.Manager
+	redact   map[string]bool
+	queue    chan registry.Invocation
+	logger   *slog.Logger
+}
+
+// newrecorder creates a recorder that stores invocations in reg
+func NewRecorder(config Config, reg *registry.Manager) *Recorder {
+	redact := make(map[string]bool, len(config.Redact))
+	for _, field := range config.Redact {
+		redact[strings.ToLower(strings.TrimSpace(field))] = true
+	}
+	return &Recorder{
+		config:   config,
+		registry: reg,
+		redact:   redact,
+		queue:    make(chan registry.Invocation, queueSize),
+		logger:   logging.Component("history"),
+	}
+}
+
+// maxbodybytes is how much of each body callers should capture
+func (r *Recorder) MaxBodyBytes() int {
+	return r.config.MaxBodyBytes
+}
+
+// record queues an invocation, keeping its bodies if it is sampled or failed
+// bodies longer than MaxBodyBytes should already be cut and flagged as truncated
+func (r *Recorder) Record(inv registry.Invocation) {
+	if inv.Status >= 500 || rand.Float64() < r.config.SampleRate {
+		inv.RequestBody = r.clean(inv.RequestBody)
+		inv.ResponseBody = r.clean(inv.ResponseBody)
+	} else {
+		inv.RequestBody, inv.ResponseBody = nil, nil
+		inv.RequestTruncated, inv.ResponseTruncated = false, false
+	}
+
+	select {
+	case r.queue <- inv:
+	default:
+		droppedInvocations.Inc()
+	}
+}
+
+// clean redacts a body, the result is never nil so an empty body still counts as kept
+func (r *Recorder) clean(body []byte) []byte {
+	if len(r.redact) > 0 {
+		body = Redact(body, r.redact)
+	}
+	if body == nil {
+		body = []byte{}
+	}
+	return body
+}
+
+// start writes queued invocations and prunes expired ones until ctx is done
+func (r *Recorder) Start(ctx context.Context) {
+	r.logger.Info("background job started", "sample_rate", r.config.SampleRate, "retention", r.config.Retention)
+
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+	r.prune()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case inv := <-r.queue:
+			if err := r.registry.RecordInvocation(inv); err != nil {
+				r.logger.Error("failed to record invocation", "function", inv.Function, "error", err)
+			}
+		case <-prune.C:
+			r.prune()
+		}
+	}
+}
+
+func (r *Recorder) prune() {
+	if r.config.Retention <= 0 {
+		return
+	}
+	n, err := r.registry.PruneInvocations(time.Now().Add(-r.config.Retention))
+	if err != nil {
+		r.logger.Error("failed to prune invocations", "error", err)
+		return
+	}
+	if n > 0 {
+		r.logger.Info("pruned invocations", "count", n, "retention", r.config.Retention)
+	}
+}
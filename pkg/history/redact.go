@@ -0,0 +1,69 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the value of redacted fields
+const Redacted = "[REDACTED]"
+
+// redact replaces the values of the given json fields, at any depth, with Redacted
+// fields maps lowercased field names to true
+// bodies without such fields are returned unchanged, so they can be replayed as they were sent
+// bodies that aren't valid json, e.g. cut at the size limit, are redacted textually
+func Redact(body []byte, fields map[string]bool) []byte {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return redactText(body, fields)
+	}
+	if !redactValue(v, fields) {
+		return body
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return redactText(body, fields)
+	}
+	return out
+}
+
+// redactvalue redacts v in place and reports whether anything was redacted
+func redactValue(v interface{}, fields map[string]bool) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if fields[strings.ToLower(k)] {
+				t[k] = Redacted
+				changed = true
+			} else if redactValue(child, fields) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, child := range t {
+			if redactValue(child, fields) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// textField matches a "key": value pair whose value is a string, number, boolean or null
+// a string cut off by truncation matches up to the end of the body
+var textField = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"(\s*:\s*)("(?:[^"\\]|\\.)*(?:"|$)|-?[0-9][0-9.eE+-]*|true|false|null)`)
+
+func redactText(body []byte, fields map[string]bool) []byte {
+	return textField.ReplaceAllFunc(body, func(m []byte) []byte {
+		sub := textField.FindSubmatch(m)
+		if !fields[strings.ToLower(string(sub[1]))] {
+			return m
+		}
+		return []byte(`"` + string(sub[1]) + `"` + string(sub[2]) + `"` + Redacted + `"`)
+	})
+}
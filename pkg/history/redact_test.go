@@ -0,0 +1,28 @@
+package history
+
+import "testing"
+
+var testFields = map[string]bool{"password": true, "token": true}
+
+func TestRedactJSON(t *testing.T) {
+	body := `{"items":[{"Token":"a"},{"id":2}],"auth":{"password":{"old":"x","new":"y"}}}`
+	want := `{"auth":{"password":"[REDACTED]"},"items":[{"Token":"[REDACTED]"},{"id":2}]}`
+	if got := string(Redact([]byte(body), testFields)); got != want {
+		t.Errorf("Redact(%s) = %s, want %s", body, got, want)
+	}
+
+	// kept byte for byte so it replays as it was sent
+	body = `{"user": "ada",  "n": 1}`
+	if got := string(Redact([]byte(body), testFields)); got != body {
+		t.Errorf("body without sensitive fields changed to %s", got)
+	}
+}
+
+func TestRedactTruncatedBody(t *testing.T) {
+	// cut at HISTORY_MAX_BODY_BYTES, so not valid json any more
+	body := `{"user":"ada","token": "abc", "password":"hunt`
+	want := `{"user":"ada","token": "[REDACTED]", "password":"[REDACTED]"`
+	if got := string(Redact([]byte(body), testFields)); got != want {
+		t.Errorf("Redact(%s) = %s, want %s", body, got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// RequestIDHeader carries the request id from callers, back in responses and on to functions
+const RequestIDHeader = "X-Request-ID"
+
+// setup makes a json handler writing to w the default logger
+// level is debug, info, warn or error; empty means info
+// every record logged with a context carrying a request id gets a request_id attribute
+func Setup(w io.Writer, level string) error {
+	var l slog.Level
+	if level != "" {
+		if err := l.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("invalid log level %q (want debug, info, warn or error)", level)
+		}
+	}
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: l, ReplaceAttr: readableDurations})
+	slog.SetDefault(slog.New(contextHandler{h}))
+	return nil
+}
+
+// component returns the default logger tagged with a component attribute (reaper, docker, ...)
+// call it after setup, loggers keep the handler that was the default when they were made
+func Component(name string) *slog.Logger {
+	return slog.Default().With("component", name)
+}
+
+type requestIDKey struct{}
+
+// withrequestid returns a context whose log records carry the request id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestid returns the request id of ctx, or "" if it has none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newrequestid generates a random 128 bit request id
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validrequestid reports whether a caller supplied id is safe to log and forward:
+// at most 128 printable ascii characters
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	return !strings.ContainsFunc(id, func(r rune) bool { return r < 0x21 || r > 0x7e })
+}
+
+// readabledurations logs durations as "1.5s" instead of nanoseconds
+func readableDurations(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindDuration {
+		return slog.String(a.Key, a.Value.Duration().String())
+	}
+	return a
+}
+
+// contexthandler adds the request id of the record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,154 @@
+package metering
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/logging"
+	"github.com/nikhi/nanolambda/pkg/registry"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	gbSeconds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "function_gb_seconds_total",
+			Help: "Metered usage in GB-seconds, by kind (invocation, idle)",
+		},
+		[]string{"function", "kind"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(gbSeconds)
+}
+
+// meter kinds
+const (
+	KindInvocation = "invocation" // duration of a request x configured memory
+	KindIdle       = "idle"       // a kept-alive or pre-warmed container waiting x configured memory
+)
+
+// meter rolls usage up per function and hour and flushes it to the registry
+type Meter struct {
+	registry *registry.Manager
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	pending map[string]map[int64]*registry.UsageRecord // function -> hour (unix) -> usage
+}
+
+// newmeter creates a meter that persists to reg
+func NewMeter(reg *registry.Manager) *Meter {
+	return &Meter{
+		registry: reg,
+		logger:   logging.Component("metering"),
+		pending:  make(map[string]map[int64]*registry.UsageRecord),
+	}
+}
+
+// invocation meters one request that took d on a container with memoryMB of memory
+func (m *Meter) Invocation(function string, d time.Duration, memoryMB int64) {
+	gb := d.Seconds() * float64(memoryMB) / 1024
+	gbSeconds.WithLabelValues(base(function), KindInvocation).Add(gb)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.record(function, time.Now())
+	r.Invocations++
+	r.GBSeconds += gb
+}
+
+// idle meters a warm container of memoryMB that held its memory without serving requests
+// from from to to, split over the hours it spans; its signature matches reaper.IdleRecorder
+func (m *Meter) Idle(function string, from, to time.Time, memoryMB int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for from.Before(to) {
+		end := from.UTC().Truncate(time.Hour).Add(time.Hour)
+		if end.After(to) {
+			end = to
+		}
+		gb := end.Sub(from).Seconds() * float64(memoryMB) / 1024
+		gbSeconds.WithLabelValues(base(function), KindIdle).Add(gb)
+		m.record(function, from).IdleGBSeconds += gb
+		from = end
+	}
+}
+
+// record returns the pending rollup of a function's hour
+// callers must hold m.mu
+func (m *Meter) record(function string, now time.Time) *registry.UsageRecord {
+	function = base(function)
+	hour := now.UTC().Truncate(time.Hour)
+	if m.pending[function] == nil {
+		m.pending[function] = make(map[int64]*registry.UsageRecord)
+	}
+	r, ok := m.pending[function][hour.Unix()]
+	if !ok {
+		r = &registry.UsageRecord{Hour: hour}
+		m.pending[function][hour.Unix()] = r
+	}
+	return r
+}
+
+// start flushes rollups every interval until ctx is done
+func (m *Meter) Start(ctx context.Context, interval time.Duration) {
+	m.logger.Info("background job started")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.Flush()
+			return
+		case <-ticker.C:
+			m.Flush()
+		}
+	}
+}
+
+// flush adds the pending rollups to the registry
+// rollups that fail to save are kept for the next flush
+func (m *Meter) Flush() {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[string]map[int64]*registry.UsageRecord)
+	m.mu.Unlock()
+
+	for function, hours := range pending {
+		for hour, r := range hours {
+			if err := m.registry.AddUsage(function, *r); err != nil {
+				m.logger.Error("failed to save usage", "function", function, "error", err)
+				m.restore(function, hour, r)
+			}
+		}
+	}
+}
+
+// restore merges an unsaved rollup back into the pending ones
+func (m *Meter) restore(function string, hour int64, r *registry.UsageRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending[function] == nil {
+		m.pending[function] = make(map[int64]*registry.UsageRecord)
+	}
+	if cur, ok := m.pending[function][hour]; ok {
+		cur.Invocations += r.Invocations
+		cur.GBSeconds += r.GBSeconds
+		cur.IdleGBSeconds += r.IdleGBSeconds
+		return
+	}
+	m.pending[function][hour] = r
+}
+
+// base strips a pinned version, usage is billed to the function
+func base(key string) string {
+	name, _, _ := strings.Cut(key, "@")
+	return name
+}
@@ -0,0 +1,116 @@
+package predictor
+
+import "math"
+
+// forecast predicts the next horizon values of an evenly spaced series
+// with two full seasons of history it fits an additive holt-winters model,
+// with one season it uses seasonal naive with drift, otherwise naive with drift
+// forecasts are never negative since they are request counts
+func Forecast(series []float64, season, horizon int) []float64 {
+	var out []float64
+	switch {
+	case season > 1 && len(series) >= 2*season:
+		out = holtWinters(series, season, horizon)
+	case season > 1 && len(series) > season:
+		out = seasonalNaive(series, season, horizon)
+	default:
+		out = naive(series, horizon)
+	}
+
+	for i := range out {
+		out[i] = math.Max(0, out[i])
+	}
+	return out
+}
+
+// drift is the average change per step over the whole series
+func drift(series []float64) float64 {
+	if len(series) < 2 {
+		return 0
+	}
+	return (series[len(series)-1] - series[0]) / float64(len(series)-1)
+}
+
+func naive(series []float64, horizon int) []float64 {
+	out := make([]float64, horizon)
+	if len(series) == 0 {
+		return out
+	}
+	last, d := series[len(series)-1], drift(series)
+	for h := 1; h <= horizon; h++ {
+		out[h-1] = last + float64(h)*d
+	}
+	return out
+}
+
+func seasonalNaive(series []float64, season, horizon int) []float64 {
+	n, d := len(series), drift(series)
+	out := make([]float64, horizon)
+	for h := 1; h <= horizon; h++ {
+		// same point in the most recent season that has it
+		k := (h-1)/season + 1
+		out[h-1] = series[n+h-1-k*season] + float64(h)*d
+	}
+	return out
+}
+
+// hwgrid is the set of smoothing parameters tried when fitting holt-winters
+var hwGrid = []float64{0.05, 0.2, 0.4, 0.6, 0.8}
+
+func holtWinters(series []float64, season, horizon int) []float64 {
+	best := math.Inf(1)
+	var bestParams [3]float64
+	for _, alpha := range hwGrid {
+		for _, beta := range hwGrid {
+			for _, gamma := range hwGrid {
+				sse, _ := fitHoltWinters(series, season, alpha, beta, gamma, 0)
+				if sse < best {
+					best = sse
+					bestParams = [3]float64{alpha, beta, gamma}
+				}
+			}
+		}
+	}
+	_, out := fitHoltWinters(series, season, bestParams[0], bestParams[1], bestParams[2], horizon)
+	return out
+}
+
+// fitholtwinters runs the additive holt-winters recursions over series
+// returns the sum of squared one-step errors and a forecast of horizon steps
+func fitHoltWinters(series []float64, season int, alpha, beta, gamma float64, horizon int) (float64, []float64) {
+	// initialise from the first two seasons
+	var first, second float64
+	for i := 0; i < season; i++ {
+		first += series[i]
+		second += series[season+i]
+	}
+	first /= float64(season)
+	second /= float64(season)
+
+	level := first
+	trend := (second - first) / float64(season)
+	seasonal := make([]float64, season)
+	for i := 0; i < season; i++ {
+		seasonal[i] = series[i] - first
+	}
+
+	var sse float64
+	for t := season; t < len(series); t++ {
+		s := seasonal[t%season]
+		predicted := level + trend + s
+		err := series[t] - predicted
+		sse += err * err
+
+		prevLevel := level
+		level = alpha*(series[t]-s) + (1-alpha)*(level+trend)
+		trend = beta*(level-prevLevel) + (1-beta)*trend
+		seasonal[t%season] = gamma*(series[t]-level) + (1-gamma)*s
+	}
+
+	out := make([]float64, horizon)
+	n := len(series)
+	for h := 1; h <= horizon; h++ {
+		out[h-1] = level + float64(h)*trend + seasonal[(n+h-1)%season]
+	}
+	return sse, out
+}
@@ -0,0 +1,43 @@
+package predictor
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestForecastPicksModelByHistory(t *testing.T) {
+	tests := []struct {
+		name   string
+		series []float64
+		want   []float64
+	}{
+		{"no history", nil, []float64{0, 0, 0}},
+		{"naive with drift below one season", []float64{1, 2, 3}, []float64{4, 5, 6}},
+		{"clamped at zero", []float64{5, 3, 1}, []float64{0, 0, 0}},
+		{"seasonal naive with one season", []float64{0, 10, 0, 0, 0}, []float64{10, 0, 0}},
+	}
+	for _, tt := range tests {
+		if got := Forecast(tt.series, 4, 3); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Forecast = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHoltWintersFollowsSeasonAndTrend(t *testing.T) {
+	pattern := []float64{10, 40, 25, 5}
+	for _, trend := range []float64{0, 0.5} {
+		at := func(i int) float64 { return pattern[i%len(pattern)] + trend*float64(i) }
+
+		series := make([]float64, 8*len(pattern))
+		for i := range series {
+			series[i] = at(i)
+		}
+		got := Forecast(series, len(pattern), len(pattern))
+		for h, v := range got {
+			if want := at(len(series) + h); math.Abs(v-want) > 1 {
+				t.Errorf("trend %v: step %d = %.2f, want %.2f", trend, h+1, v, want)
+			}
+		}
+	}
+}
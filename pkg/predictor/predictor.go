@@ -0,0 +1,273 @@
+package predictor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/logging"
+	"github.com/nikhi/nanolambda/pkg/registry"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	forecastRequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "predictor_forecast_requests",
+			Help: "Requests expected in the forecast window, per function",
+		},
+		[]string{"function"},
+	)
+	predictorWarmups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "predictor_warmups_total",
+			Help: "Warmups triggered by the built-in predictor",
+		},
+		[]string{"function"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(forecastRequests, predictorWarmups)
+}
+
+// warmer pre-warms a function ahead of predicted traffic
+type Warmer func(ctx context.Context, function string) error
+
+// config tunes the predictor, the defaults match prophet/main.py
+type Config struct {
+	Bucket    time.Duration // width of one request-count bucket
+	Interval  time.Duration // how often forecasts run
+	Horizon   int           // buckets ahead; the last one is compared to the threshold
+	Season    time.Duration // seasonal period of the model
+	Lookback  time.Duration // history kept in memory and in the registry
+	MinPoints int           // functions with fewer buckets are skipped
+	Threshold float64       // warm when more requests than this are expected
+}
+
+// defaultconfig returns the same settings as the prophet service:
+// 5 minute buckets, a 10 minute forecast every 5 minutes, 7 days of history,
+// at least 20 points and a threshold of 5 requests
+func DefaultConfig() Config {
+	return Config{
+		Bucket:    5 * time.Minute,
+		Interval:  5 * time.Minute,
+		Horizon:   2,
+		Season:    24 * time.Hour,
+		Lookback:  7 * 24 * time.Hour,
+		MinPoints: 20,
+		Threshold: 5.0,
+	}
+}
+
+// series returns bucket counts (bucket start in unix seconds -> requests) as evenly spaced
+// points, from the oldest bucket in the lookback window up to the last complete one before now,
+// with empty buckets filled in as zero
+func (c Config) Series(buckets map[int64]float64, now time.Time) []float64 {
+	step := int64(c.Bucket / time.Second)
+	current := now.Truncate(c.Bucket).Unix()
+	oldest := now.Add(-c.Lookback).Unix()
+
+	first := current
+	for start := range buckets {
+		if start >= oldest && start < first {
+			first = start
+		}
+	}
+
+	var series []float64
+	for start := first; start < current; start += step {
+		series = append(series, buckets[start])
+	}
+	return series
+}
+
+// predict forecasts the requests in the last bucket of the horizon
+// ok is false for series shorter than MinPoints, which aren't forecast
+func (c Config) Predict(series []float64) (predicted float64, ok bool) {
+	if len(series) < c.MinPoints {
+		return 0, false
+	}
+	forecast := Forecast(series, int(c.Season/c.Bucket), c.Horizon)
+	return forecast[len(forecast)-1], true
+}
+
+// predictor forecasts per-function request rates and pre-warms ahead of spikes
+type Predictor struct {
+	config   Config
+	registry *registry.Manager
+	warm     Warmer
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	series map[string]map[int64]float64 // function -> bucket start (unix) -> requests
+	dirty  map[string]map[int64]bool    // buckets not yet persisted
+}
+
+// newpredictor creates a predictor that persists to reg and warms through warm
+func NewPredictor(config Config, reg *registry.Manager, warm Warmer) *Predictor {
+	return &Predictor{
+		config:   config,
+		registry: reg,
+		warm:     warm,
+		logger:   logging.Component("predictor"),
+		series:   make(map[string]map[int64]float64),
+		dirty:    make(map[string]map[int64]bool),
+	}
+}
+
+// record counts one request for a function
+func (p *Predictor) Record(function string) {
+	if p == nil {
+		return
+	}
+	bucket := time.Now().Truncate(p.config.Bucket).Unix()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.add(function, bucket, 1)
+}
+
+// add increments a bucket; callers must hold p.mu
+func (p *Predictor) add(function string, bucket int64, count float64) {
+	if p.series[function] == nil {
+		p.series[function] = make(map[int64]float64)
+		p.dirty[function] = make(map[int64]bool)
+	}
+	p.series[function][bucket] += count
+	p.dirty[function][bucket] = true
+}
+
+// load restores history from the registry
+func (p *Predictor) load() error {
+	rates, err := p.registry.LoadRequestRates(time.Now().Add(-p.config.Lookback))
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for function, points := range rates {
+		if p.series[function] == nil {
+			p.series[function] = make(map[int64]float64)
+			p.dirty[function] = make(map[int64]bool)
+		}
+		for _, point := range points {
+			p.series[function][point.Start.Unix()] += point.Count
+		}
+	}
+	return nil
+}
+
+// start loads stored history and runs the forecast loop until ctx is done
+func (p *Predictor) Start(ctx context.Context) {
+	if err := p.load(); err != nil {
+		p.logger.Error("failed to load request history", "error", err)
+	}
+	p.logger.Info("background job started")
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	// run immediately on startup, like the prophet service
+	p.cycle(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			p.persist()
+			p.logger.Info("stopping background job")
+			return
+		case <-ticker.C:
+			p.cycle(ctx)
+		}
+	}
+}
+
+// cycle persists new counts, then forecasts every function and warms the busy ones
+func (p *Predictor) cycle(ctx context.Context) {
+	p.persist()
+	p.prune()
+
+	for function, series := range p.snapshot() {
+		predicted, ok := p.config.Predict(series)
+		if !ok {
+			continue
+		}
+		forecastRequests.WithLabelValues(function).Set(predicted)
+
+		if predicted <= p.config.Threshold {
+			continue
+		}
+		p.logger.Info("traffic expected, warming", "function", function, "predicted", predicted, "threshold", p.config.Threshold)
+		if err := p.warm(ctx, function); err != nil {
+			p.logger.Warn("warmup failed", "function", function, "error", err)
+			continue
+		}
+		predictorWarmups.WithLabelValues(function).Inc()
+	}
+}
+
+// snapshot returns the series of every function with a complete bucket
+func (p *Predictor) snapshot() map[string][]float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	out := make(map[string][]float64)
+	for function, buckets := range p.series {
+		if series := p.config.Series(buckets, now); len(series) > 0 {
+			out[function] = series
+		}
+	}
+	return out
+}
+
+// persist writes changed buckets to the registry
+func (p *Predictor) persist() {
+	p.mu.Lock()
+	type pending struct {
+		function string
+		point    registry.RatePoint
+	}
+	var writes []pending
+	for function, buckets := range p.dirty {
+		for start := range buckets {
+			writes = append(writes, pending{function, registry.RatePoint{
+				Start: time.Unix(start, 0),
+				Count: p.series[function][start],
+			}})
+		}
+		p.dirty[function] = make(map[int64]bool)
+	}
+	p.mu.Unlock()
+
+	for _, w := range writes {
+		if err := p.registry.SaveRequestRate(w.function, w.point); err != nil {
+			p.logger.Error("failed to persist request rate", "function", w.function, "error", err)
+		}
+	}
+}
+
+// prune drops history older than the lookback window
+func (p *Predictor) prune() {
+	cutoff := time.Now().Add(-p.config.Lookback)
+
+	p.mu.Lock()
+	for function, buckets := range p.series {
+		for start := range buckets {
+			if start < cutoff.Unix() {
+				delete(buckets, start)
+			}
+		}
+		if len(buckets) == 0 {
+			delete(p.series, function)
+			delete(p.dirty, function)
+		}
+	}
+	p.mu.Unlock()
+
+	if err := p.registry.PruneRequestRates(cutoff); err != nil {
+		p.logger.Error("failed to prune request history", "error", err)
+	}
+}
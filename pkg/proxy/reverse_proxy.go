@@ -1,10 +1,11 @@
 package proxy
 
 import (
-	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+
+	"github.com/nikhi/nanolambda/pkg/logging"
 )
 
 // NewReverseProxy creates a proxy that forwards requests to the container
@@ -28,7 +29,7 @@ func NewReverseProxy(targetAddr string) *httputil.ReverseProxy {
 	
 	// Error handler
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		fmt.Printf("Proxy error: %v\n", err)
+		logging.Component("proxy").ErrorContext(r.Context(), "proxy error", "target", targetAddr, "error", err)
 		http.Error(w, "Bad Gateway", http.StatusBadGateway)
 	}
 
@@ -0,0 +1,315 @@
+package reaper
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/logging"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	desiredReplicas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "autoscaler_desired_replicas",
+			Help: "Replicas the autoscaler wants per function",
+		},
+		[]string{"function"},
+	)
+	actualReplicas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "autoscaler_actual_replicas",
+			Help: "Replicas registered per function, including paused ones",
+		},
+		[]string{"function"},
+	)
+	panicMode = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "autoscaler_panic_mode",
+			Help: "1 while the autoscaler is in panic mode for a function",
+		},
+		[]string{"function"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(desiredReplicas, actualReplicas, panicMode)
+}
+
+// bounds limits the replicas of one function
+type Bounds struct {
+	Target int // in-flight requests per replica to aim for, 0 uses the default
+	Min    int // replicas kept at all times (the reaper enforces the floor)
+	Max    int // 0 uses the default
+}
+
+// autoscalerconfig tunes the autoscaler
+type AutoscalerConfig struct {
+	Interval       time.Duration // how often concurrency is sampled and replicas adjusted
+	StableWindow   time.Duration // averaging window for normal scaling decisions
+	PanicWindow    time.Duration // short window used to react to bursts
+	PanicThreshold float64       // enter panic when the panic window wants this many times the current replicas
+	ScaleDownDelay time.Duration // at most one replica is removed per delay
+	DefaultTarget  int           // target concurrency for functions that don't set one
+	DefaultMax     int           // max replicas for functions that don't set one
+}
+
+// defaultautoscalerconfig returns a 60s stable window and a 6s panic window
+func DefaultAutoscalerConfig() AutoscalerConfig {
+	return AutoscalerConfig{
+		Interval:       time.Second,
+		StableWindow:   60 * time.Second,
+		PanicWindow:    6 * time.Second,
+		PanicThreshold: 2.0,
+		ScaleDownDelay: 10 * time.Second,
+		DefaultTarget:  5,
+		DefaultMax:     10,
+	}
+}
+
+// autoscaler sizes each function's replicas to its in-flight requests
+// scale from zero is left to cold starts and scale to zero to the keep-alive policy
+type Autoscaler struct {
+	config  AutoscalerConfig
+	reaper  *Manager
+	mu      sync.Mutex
+	bounds  map[string]Bounds
+	history map[string]*scaleHistory
+	logger  *slog.Logger
+}
+
+// scalehistory is the autoscaler state of one function
+type scaleHistory struct {
+	samples       []concurrencySample
+	desired       int
+	panicUntil    time.Time
+	lastScaleDown time.Time
+}
+
+type concurrencySample struct {
+	at       time.Time
+	inFlight int
+}
+
+// newautoscaler creates an autoscaler for the replicas tracked by m
+func NewAutoscaler(m *Manager, config AutoscalerConfig) *Autoscaler {
+	return &Autoscaler{
+		config:  config,
+		reaper:  m,
+		bounds:  make(map[string]Bounds),
+		history: make(map[string]*scaleHistory),
+		logger:  logging.Component("autoscaler"),
+	}
+}
+
+// setbounds replaces the per-function bounds, keyed by function name
+func (a *Autoscaler) SetBounds(bounds map[string]Bounds) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.bounds = bounds
+}
+
+// start runs the autoscaling loop until ctx is done
+func (a *Autoscaler) Start(ctx context.Context) {
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+
+	a.logger.Info("background job started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.logger.Info("stopping background job")
+			return
+		case <-ticker.C:
+			a.scale(ctx, time.Now())
+		}
+	}
+}
+
+// scale samples every function's concurrency and adjusts its replicas
+func (a *Autoscaler) scale(ctx context.Context, now time.Time) {
+	loads := a.reaper.loads()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key, load := range loads {
+		h, ok := a.history[key]
+		if !ok {
+			h = &scaleHistory{}
+			a.history[key] = h
+		}
+		h.samples = append(h.samples, concurrencySample{at: now, inFlight: load.inFlight})
+		h.trim(now.Add(-a.config.StableWindow))
+
+		desired := a.desired(key, h, load.replicas, now)
+		desiredReplicas.WithLabelValues(key).Set(float64(desired))
+		actualReplicas.WithLabelValues(key).Set(float64(load.replicas))
+
+		current := load.replicas + load.starting
+		switch {
+		case desired > current:
+			a.logger.Info("scaling up", "function", key, "from", current, "to", desired, "in_flight", load.inFlight)
+			a.reaper.scaleUp(ctx, key, desired-current)
+		case desired < load.replicas && now.Sub(h.lastScaleDown) >= a.config.ScaleDownDelay:
+			// the last replica is left to the keep-alive policy
+			if load.replicas > 1 && a.reaper.scaleDown(key) {
+				h.lastScaleDown = now
+				a.logger.Info("scaled down", "function", key, "replicas", load.replicas-1, "desired", desired)
+			}
+		}
+	}
+
+	// forget functions that scaled to zero
+	for key := range a.history {
+		if _, ok := loads[key]; !ok {
+			delete(a.history, key)
+			desiredReplicas.DeleteLabelValues(key)
+			actualReplicas.DeleteLabelValues(key)
+			panicMode.DeleteLabelValues(key)
+		}
+	}
+}
+
+// desired computes the replicas a function should run
+// callers must hold a.mu
+func (a *Autoscaler) desired(key string, h *scaleHistory, replicas int, now time.Time) int {
+	b := a.boundsFor(key)
+
+	stable := h.average(now.Add(-a.config.StableWindow))
+	burst := h.average(now.Add(-a.config.PanicWindow))
+	stableWant := int(math.Ceil(stable / float64(b.Target)))
+	panicWant := int(math.Ceil(burst / float64(b.Target)))
+
+	if replicas > 0 && float64(panicWant) >= a.config.PanicThreshold*float64(replicas) {
+		if !now.Before(h.panicUntil) {
+			a.logger.Warn("entering panic mode", "function", key, "in_flight", burst, "window", a.config.PanicWindow)
+		}
+		h.panicUntil = now.Add(a.config.StableWindow)
+	}
+
+	want := stableWant
+	if now.Before(h.panicUntil) {
+		// scale up on the short window and never down until the burst has passed
+		want = max(panicWant, stableWant, h.desired)
+		panicMode.WithLabelValues(key).Set(1)
+	} else {
+		panicMode.WithLabelValues(key).Set(0)
+	}
+
+	want = max(want, b.Min)
+	if want > b.Max {
+		want = b.Max
+	}
+	h.desired = want
+	return want
+}
+
+// boundsfor returns the bounds of a reaper key with defaults filled in
+// pinned versions ("name@2") share the bounds of their function
+// callers must hold a.mu
+func (a *Autoscaler) boundsFor(key string) Bounds {
+	name, _, _ := strings.Cut(key, "@")
+	b := a.bounds[name]
+	if b.Target <= 0 {
+		b.Target = a.config.DefaultTarget
+	}
+	if b.Max <= 0 {
+		b.Max = a.config.DefaultMax
+	}
+	if b.Max < b.Min {
+		b.Max = b.Min
+	}
+	return b
+}
+
+// trim drops samples taken before since
+func (h *scaleHistory) trim(since time.Time) {
+	i := sort.Search(len(h.samples), func(i int) bool { return !h.samples[i].at.Before(since) })
+	h.samples = h.samples[i:]
+}
+
+// average returns the mean in-flight requests of the samples taken since a time
+func (h *scaleHistory) average(since time.Time) float64 {
+	sum, n := 0, 0
+	for _, s := range h.samples {
+		if !s.at.Before(since) {
+			sum += s.inFlight
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(sum) / float64(n)
+}
+
+// load is a snapshot of one function's replicas
+type load struct {
+	replicas int
+	starting int
+	inFlight int
+}
+
+// loads returns the replicas and in-flight requests of every function with replicas
+func (m *Manager) loads() map[string]load {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make(map[string]load, len(m.containers))
+	for name, replicas := range m.containers {
+		l := load{replicas: m.active(name), starting: m.provisioning[name]}
+		for _, info := range replicas {
+			l.inFlight += info.InFlight
+		}
+		out[name] = l
+	}
+	return out
+}
+
+// scaleup starts n more replicas of a function through the launcher
+func (m *Manager) scaleUp(ctx context.Context, name string, n int) {
+	m.mu.Lock()
+	launch := m.launcher
+	if launch == nil {
+		m.mu.Unlock()
+		return
+	}
+	m.provisioning[name] += n
+	m.mu.Unlock()
+
+	m.launchReplicas(ctx, launch, name, n, OriginAutoscale)
+}
+
+// scaledown stops the longest idle replica of a function that isn't serving anything
+// returns false if every replica is busy or the function is at its minimum
+func (m *Manager) scaleDown(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.atFloor(name) {
+		return false
+	}
+	var victim *ContainerInfo
+	for _, info := range m.containers[name] {
+		if info.InFlight > 0 || info.busy != nil {
+			continue
+		}
+		if victim == nil || info.LastAccessed.Before(victim.LastAccessed) {
+			victim = info
+		}
+	}
+	if victim == nil {
+		return false
+	}
+
+	m.stopReplica(name, victim, m.now(), "autoscale")
+	m.updateStateMetrics()
+	return true
+}
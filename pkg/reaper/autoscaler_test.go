@@ -0,0 +1,72 @@
+package reaper
+
+import (
+	"testing"
+	"time"
+)
+
+var scaleNow = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// history returns a minute of one-second samples ending at scaleNow
+// inFlight gets the age of each sample in seconds
+func history(inFlight func(age int) int) *scaleHistory {
+	h := &scaleHistory{}
+	for age := 59; age >= 0; age-- {
+		h.samples = append(h.samples, concurrencySample{at: scaleNow.Add(-time.Duration(age) * time.Second), inFlight: inFlight(age)})
+	}
+	return h
+}
+
+func steady(n int) func(int) int { return func(int) int { return n } }
+
+func TestAutoscalerStableWindow(t *testing.T) {
+	a := NewAutoscaler(nil, DefaultAutoscalerConfig())
+	a.SetBounds(map[string]Bounds{"capped": {Max: 4}, "min": {Min: 3}})
+
+	// default target 5 per replica, rounded up
+	if got := a.desired("fn", history(steady(11)), 2, scaleNow); got != 3 {
+		t.Errorf("11 in flight: desired = %d, want 3", got)
+	}
+	if got := a.desired("fn", history(steady(0)), 1, scaleNow); got != 0 {
+		t.Errorf("no load: desired = %d, want 0", got)
+	}
+	if got := a.desired("capped", history(steady(100)), 4, scaleNow); got != 4 {
+		t.Errorf("max_instances 4: desired = %d, want 4", got)
+	}
+	if got := a.desired("min", history(steady(0)), 3, scaleNow); got != 3 {
+		t.Errorf("min_instances 3: desired = %d, want 3", got)
+	}
+}
+
+func TestAutoscalerPanicWindow(t *testing.T) {
+	a := NewAutoscaler(nil, DefaultAutoscalerConfig())
+	// 40 in flight over the last 6 seconds only
+	burst := func(age int) int {
+		if age <= 6 {
+			return 40
+		}
+		return 0
+	}
+
+	h := history(burst)
+	if got := a.desired("fn", h, 1, scaleNow); got != 8 || !scaleNow.Before(h.panicUntil) {
+		t.Fatalf("burst on 1 replica: desired = %d, panicking = %v; want 8 and panic", got, scaleNow.Before(h.panicUntil))
+	}
+
+	// 5 replicas can take the burst at 1.6x the target, under the 2x threshold
+	h = history(burst)
+	if got := a.desired("fn", h, 5, scaleNow); got != 1 || scaleNow.Before(h.panicUntil) {
+		t.Errorf("burst on 5 replicas: desired = %d, panicking = %v; want 1 without panic", got, scaleNow.Before(h.panicUntil))
+	}
+
+	// load gone while panicking: hold the replicas until the panic ends
+	h = history(steady(0))
+	h.desired, h.panicUntil = 8, scaleNow.Add(30*time.Second)
+	if got := a.desired("fn", h, 8, scaleNow); got != 8 {
+		t.Errorf("during panic: desired = %d, want 8", got)
+	}
+	h.panicUntil = scaleNow
+	if got := a.desired("fn", h, 8, scaleNow); got != 0 {
+		t.Errorf("after panic: desired = %d, want 0", got)
+	}
+}
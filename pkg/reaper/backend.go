@@ -0,0 +1,28 @@
+package reaper
+
+import (
+	"context"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/docker"
+)
+
+// backend runs the containers the reaper manages
+// the gateway uses *docker.Manager, the simulator a fake that only keeps the books
+type Backend interface {
+	StartContainer(ctx context.Context, spec docker.ContainerSpec) (string, string, error)
+	StopContainer(ctx context.Context, containerID string) error
+	PauseContainer(ctx context.Context, containerID string) error
+	UnpauseContainer(ctx context.Context, containerID string) error
+}
+
+// clock returns the current time
+type Clock func() time.Time
+
+// setclock replaces the time source, the simulator drives the reaper with a virtual clock
+// idle times, keep-alive deadlines and pre-warms all follow it
+func (m *Manager) SetClock(c Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = c
+}
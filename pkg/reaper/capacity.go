@@ -0,0 +1,188 @@
+package reaper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/events"
+)
+
+// defaultmemorymb is assumed for containers without a configured memory limit (pooled runners)
+const defaultMemoryMB = 128
+
+// capacityconfig is the host budget for function containers, zero fields are unlimited
+type CapacityConfig struct {
+	MaxContainers int   // running and paused containers, pooled runners included
+	MaxMemoryMB   int64 // sum of the containers' configured memory
+	MinHostFreeMB int64 // keep at least this much MemAvailable in /proc/meminfo
+}
+
+// capacityerror explains why a container couldn't be started
+type CapacityError struct {
+	Reason string
+}
+
+func (e *CapacityError) Error() string { return "no capacity: " + e.Reason }
+
+// setcapacity sets the host budget checked before every container start
+func (m *Manager) SetCapacity(c CapacityConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.capacity = c
+}
+
+// reserve makes room for a new container with the given memory limit
+// idle containers are evicted least recently used first, starting with pooled runners
+// the reservation counts against the budget until release is called, which should happen
+// once the container is registered or has failed to start
+func (m *Manager) Reserve(ctx context.Context, memoryMB int64) (func(), error) {
+	return m.reserve(ctx, memoryMB, true)
+}
+
+// reserve is reserve with optional eviction; the warm pool never evicts function containers
+func (m *Manager) reserve(ctx context.Context, memoryMB int64, evict bool) (func(), error) {
+	if memoryMB <= 0 {
+		memoryMB = defaultMemoryMB
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		limit, reason := m.overBudget(memoryMB)
+		if limit == "" {
+			break
+		}
+		if !evict || !m.evictOne(ctx) {
+			capacityRejections.WithLabelValues(limit).Inc()
+			return nil, &CapacityError{Reason: reason}
+		}
+	}
+
+	m.reserved++
+	m.reservedMemory += memoryMB
+	released := false
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if !released {
+			released = true
+			m.reserved--
+			m.reservedMemory -= memoryMB
+		}
+	}, nil
+}
+
+// overbudget returns the limit (containers, memory or host_memory) one more container of
+// memoryMB would exceed and a description, or "" if it fits
+// callers must hold m.mu
+func (m *Manager) overBudget(memoryMB int64) (string, string) {
+	c := m.capacity
+	containers, memory := m.usage()
+
+	if c.MaxContainers > 0 && containers+1 > c.MaxContainers {
+		return "containers", fmt.Sprintf("container limit reached (%d of %d)", containers, c.MaxContainers)
+	}
+	if c.MaxMemoryMB > 0 && memory+memoryMB > c.MaxMemoryMB {
+		return "memory", fmt.Sprintf("memory budget reached (%d of %d mb in use, %d mb requested)", memory, c.MaxMemoryMB, memoryMB)
+	}
+	if c.MinHostFreeMB > 0 {
+		// containers being started haven't allocated their memory yet,
+		// and the ones being stopped (e.g. just evicted) will give theirs back
+		available, err := m.memAvailable()
+		if err == nil && available+m.stoppingMemory-m.reservedMemory-memoryMB < c.MinHostFreeMB {
+			return "host_memory", fmt.Sprintf("host memory low (%d mb available, %d mb kept free)", available, c.MinHostFreeMB)
+		}
+	}
+	return "", ""
+}
+
+// usage returns the containers counted against the budget and their memory
+// callers must hold m.mu
+func (m *Manager) usage() (int, int64) {
+	containers := m.reserved + len(m.pool)
+	memory := m.reservedMemory + int64(len(m.pool))*defaultMemoryMB
+	for _, replicas := range m.containers {
+		for _, info := range replicas {
+			containers++
+			if info.MemoryLimit > 0 {
+				memory += info.MemoryLimit
+			} else {
+				memory += defaultMemoryMB
+			}
+		}
+	}
+	return containers, memory
+}
+
+// evictone stops the least recently used idle container
+// pooled runners go first, busy replicas and replicas at their function's minimum are kept
+// returns false if nothing could be evicted
+// callers must hold m.mu
+func (m *Manager) evictOne(ctx context.Context) bool {
+	if len(m.pool) > 0 {
+		info := m.pool[0]
+		m.pool = m.pool[1:]
+		m.logger.InfoContext(ctx, "evicting pooled container to make room", "container", info.ID[:12])
+		m.stopContainer(poolName, info)
+		capacityEvictions.WithLabelValues(poolName).Inc()
+		evictions.WithLabelValues("capacity").Inc()
+		m.events.Publish(events.Event{Type: events.ContainerReaped, Function: poolName, Container: info.ID[:12], Reason: "capacity"})
+		return true
+	}
+
+	var victimName string
+	var victim *ContainerInfo
+	for name, replicas := range m.containers {
+		if m.atFloor(name) {
+			continue
+		}
+		for _, info := range replicas {
+			if info.InFlight > 0 || info.busy != nil {
+				continue
+			}
+			if victim == nil || info.LastAccessed.Before(victim.LastAccessed) {
+				victimName, victim = name, info
+			}
+		}
+	}
+	if victim == nil {
+		return false
+	}
+
+	m.logger.InfoContext(ctx, "evicting container to make room", "function", victimName, "container", victim.ID[:12], "idle", m.now().Sub(victim.LastAccessed).Round(time.Second))
+	m.stopReplica(victimName, victim, m.now(), "capacity")
+	capacityEvictions.WithLabelValues(victimName).Inc()
+	m.updateStateMetrics()
+	return true
+}
+
+// hostmemavailable reads MemAvailable from /proc/meminfo in mb
+func hostMemAvailable() (int64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return kb / 1024, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("MemAvailable not found in /proc/meminfo")
+}
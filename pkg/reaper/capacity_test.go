@@ -0,0 +1,65 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/docker"
+)
+
+// nopBackend stops containers without a docker daemon
+type nopBackend struct{}
+
+func (nopBackend) StartContainer(context.Context, docker.ContainerSpec) (string, string, error) {
+	return "", "", errors.New("not supported")
+}
+func (nopBackend) StopContainer(context.Context, string) error    { return nil }
+func (nopBackend) PauseContainer(context.Context, string) error   { return nil }
+func (nopBackend) UnpauseContainer(context.Context, string) error { return nil }
+
+// lowMemoryManager returns a manager that must keep 1000 mb of the host's 1100 free,
+// with n idle 128 mb replicas of fn, the first one idle the longest
+func lowMemoryManager(n int) *Manager {
+	m := NewManager(nopBackend{})
+	m.SetCapacity(CapacityConfig{MinHostFreeMB: 1000})
+	// /proc/meminfo doesn't change until docker has actually stopped an evicted container
+	m.memAvailable = func() (int64, error) { return 1100, nil }
+	for i := range n {
+		m.Register(context.Background(), "fn", ContainerInfo{ID: fmt.Sprintf("container-%08d", i), Address: "127.0.0.1:1", MemoryLimit: 128})
+		m.containers["fn"][i].LastAccessed = time.Now().Add(-time.Duration(n-i) * time.Minute)
+	}
+	return m
+}
+
+func TestReserveEvictsOnlyWhatHostMemoryNeeds(t *testing.T) {
+	m := lowMemoryManager(3)
+
+	release, err := m.Reserve(context.Background(), 128)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	defer release()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if n := len(m.containers["fn"]); n != 2 {
+		t.Errorf("%d replicas left, want 2 (only the least recently used evicted)", n)
+	}
+	if m.find("fn", "container-00000000") != nil {
+		t.Error("least recently used replica wasn't the one evicted")
+	}
+}
+
+func TestReserveRejectsWhenEvictionCantFreeEnough(t *testing.T) {
+	m := lowMemoryManager(1)
+
+	// evicting the only replica frees 128 of the 156 mb short
+	_, err := m.Reserve(context.Background(), 256)
+	var capacityErr *CapacityError
+	if !errors.As(err, &capacityErr) {
+		t.Fatalf("Reserve = %v, want a CapacityError", err)
+	}
+}
@@ -0,0 +1,179 @@
+package reaper
+
+import (
+	"context"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/events"
+)
+
+// unloadafter is how long a container is kept after a request when the policy
+// asks for it to be unloaded and pre-warmed later
+const unloadAfter = 2 * time.Second
+
+// launcher starts and registers one more container for a function
+// the gateway provides it so the reaper can pre-warm and provision functions on its own
+type Launcher func(ctx context.Context, function, origin string) error
+
+// prewarm is a scheduled policy pre-warm
+type prewarm struct {
+	at    time.Time // when to start the container
+	until time.Time // when to stop it if it hasn't served a request
+}
+
+// startcounts tallies invocations by start type for the cold-start ratio
+type startCounts struct {
+	total int
+	cold  int
+}
+
+// setpolicy replaces the keep-alive policy
+func (m *Manager) SetPolicy(p KeepAlivePolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policy = p
+	m.logger.Info("using keep-alive policy", "policy", p.Name())
+}
+
+// setlauncher sets the function used to start pre-warmed containers
+func (m *Manager) SetLauncher(l Launcher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.launcher = l
+}
+
+// recordinvocation feeds an invocation of a function to the keep-alive policy
+// it must be called before the request looks up its container so cold starts are counted
+func (m *Manager) RecordInvocation(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if last, ok := m.lastInvoked[name]; ok {
+		m.policy.Observe(name, now.Sub(last))
+	}
+	m.lastInvoked[name] = now
+
+	// a request beat the pre-warm, it cold starts instead
+	delete(m.prewarms, name)
+
+	start := "warm"
+	if m.active(name) == 0 {
+		start = "cold"
+	}
+	policy := m.policy.Name()
+	invocationsByStart.WithLabelValues(name, policy, start).Inc()
+
+	counts := m.starts[name]
+	counts.total++
+	if start == "cold" {
+		counts.cold++
+	}
+	m.starts[name] = counts
+	coldStartRatio.WithLabelValues(name, policy).Set(float64(counts.cold) / float64(counts.total))
+
+	if start == "cold" {
+		// nothing was warmed ahead of this request
+		m.recordWarmupMissed(name)
+	}
+}
+
+// deadline returns when an idle container should be stopped
+func (m *Manager) deadline(name string, info *ContainerInfo) (time.Time, Window) {
+	window := m.policy.Window(name, info.Timeout)
+	windowSeconds.WithLabelValues(name, "prewarm").Set(window.PreWarm.Seconds())
+	windowSeconds.WithLabelValues(name, "keepalive").Set(window.KeepAlive.Seconds())
+
+	if !info.ExpiresAt.IsZero() {
+		// pre-warmed and not used yet
+		return info.ExpiresAt, window
+	}
+	if window.PreWarm > 0 {
+		// the next request is expected much later: unload now, a pre-warm brings it back
+		return info.LastAccessed.Add(unloadAfter), window
+	}
+	return info.LastAccessed.Add(window.KeepAlive), window
+}
+
+// scheduleprewarm plans a container start ahead of the next expected request
+// callers must hold m.mu
+func (m *Manager) schedulePrewarm(name string, window Window) {
+	last, ok := m.lastInvoked[name]
+	if !ok || m.launcher == nil {
+		return
+	}
+	at := last.Add(window.PreWarm)
+	if at.Before(m.now()) {
+		return
+	}
+	m.prewarms[name] = prewarm{at: at, until: at.Add(window.KeepAlive)}
+	m.logger.Info("pre-warm scheduled", "function", name, "at", at.Format(time.TimeOnly))
+}
+
+// runprewarms starts the containers whose pre-warm time has come
+func (m *Manager) runPrewarms(ctx context.Context) {
+	m.mu.Lock()
+	now := m.now()
+	due := make(map[string]prewarm)
+	for name, p := range m.prewarms {
+		if now.Before(p.at) {
+			continue
+		}
+		delete(m.prewarms, name)
+		if m.active(name) == 0 {
+			due[name] = p
+			m.events.Publish(events.Event{Type: events.WarmupTriggered, Function: name, Origin: OriginPrewarm, Replicas: 1})
+		}
+	}
+	launch := m.launcher
+	m.mu.Unlock()
+
+	for name, p := range due {
+		m.background.Add(1)
+		go func(name string, p prewarm) {
+			defer m.background.Done()
+			if err := launch(ctx, name, OriginPrewarm); err != nil {
+				m.logger.WarnContext(ctx, "pre-warm failed", "function", name, "error", err)
+				return
+			}
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			// a request that arrives later clears the expiry
+			for _, info := range m.containers[name] {
+				if !info.LastAccessed.Before(p.at) {
+					info.ExpiresAt = p.until
+				}
+			}
+		}(name, p)
+	}
+}
+
+// accountidle adds the memory a container held while idle since its last request,
+// or since the idle time metered last; busy containers aren't idle
+// callers must hold m.mu
+func (m *Manager) accountIdle(name string, info *ContainerInfo, now time.Time) {
+	from := info.LastAccessed
+	if info.idleMetered.After(from) {
+		from = info.idleMetered
+	}
+	info.idleMetered = now
+	idle := now.Sub(from).Seconds()
+	if info.InFlight > 0 || idle <= 0 || info.MemoryLimit <= 0 {
+		return
+	}
+	wastedMemory.WithLabelValues(name, m.policy.Name()).Add(idle * float64(info.MemoryLimit))
+	if m.idleRecorder != nil {
+		m.idleRecorder(name, from, now, info.MemoryLimit)
+	}
+}
+
+// idlerecorder receives the time a container of memoryMB sat idle, between from and to
+type IdleRecorder func(function string, from, to time.Time, memoryMB int64)
+
+// setidlerecorder sets where idle memory is reported, e.g. for usage metering
+func (m *Manager) SetIdleRecorder(r IdleRecorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.idleRecorder = r
+}
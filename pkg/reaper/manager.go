@@ -2,113 +2,631 @@ package reaper
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/nikhi/nanolambda/pkg/docker"
+	"github.com/nikhi/nanolambda/pkg/events"
+	"github.com/nikhi/nanolambda/pkg/logging"
+)
+
+// container states
+const (
+	StateRunning  = "running"
+	StatePaused   = "paused"   // frozen after a short idle period, resumed on the next request
+	StateDraining = "draining" // past its max lifetime or max requests, finishing in-flight requests
+)
+
+// container origins, why a container was started
+const (
+	OriginRequest     = "request"     // cold start for an invocation
+	OriginWarmup      = "warmup"      // the warmup api (prophet or the built-in predictor)
+	OriginPrewarm     = "prewarm"     // the keep-alive policy
+	OriginProvisioned = "provisioned" // the function's minimum instances
+	OriginAutoscale   = "autoscale"   // the autoscaler, to meet the target concurrency
+	OriginRecycle     = "recycle"     // replacement for a replica past its max lifetime or max requests
+	OriginProbe       = "probe"       // half-open circuit breaker probe
 )
 
 // containerinfo tracks the state of a running function container
 type ContainerInfo struct {
 	ID           string
 	Address      string
+	State        string
 	LastAccessed time.Time
 	Timeout      time.Duration
+	MemoryLimit  int64     // configured memory in mb, used to account idle memory
+	ExpiresAt    time.Time // set on policy pre-warmed containers until their first request
+	FromPool     bool      // started as a generic pooled runner and bound to the function on claim
+	Origin       string    // why the container was started, one of the origin constants
+	Version      int       // function version the container runs, 0 if unknown
+	Served       int       // requests routed to this container
+	InFlight     int       // requests being served right now
+	StartedAt    time.Time
+	MaxLifetime  time.Duration // recycle after this long, 0 disables
+	MaxRequests  int           // recycle after serving this many requests, 0 disables
+
+	failures    int           // consecutive failed health checks
+	replacing   bool          // a replacement is starting, the replica is drained once it's ready
+	idleMetered time.Time     // idle time up to here has been metered
+	busy        chan struct{} // set while a pause or unpause runs without m.mu, closed when it's done
 }
 
 // manager handles the lifecycle of containers (idle cleanup)
 type Manager struct {
-	docker     *docker.Manager
+	backend    Backend
+	now        Clock
+	logger     *slog.Logger
 	mu         sync.RWMutex
-	containers map[string]*ContainerInfo // map[functionname]*containerinfo
+	containers map[string][]*ContainerInfo // map[functionname]replicas
+	next       map[string]int              // round-robin position per function
+
+	// idle containers are paused after pauseAfter and stopped after their timeout
+	// zero disables pausing
+	pauseAfter time.Duration
+
+	// keep-alive policy and the per-function state it needs across container lifetimes
+	policy      KeepAlivePolicy
+	lastInvoked map[string]time.Time
+	prewarms    map[string]prewarm
+	launcher    Launcher
+	starts      map[string]startCounts
+	warmups     map[string]*WarmupStats
+
+	// idle memory of warm containers is reported here for usage metering
+	idleRecorder IdleRecorder
+
+	// lifecycle events: registered, reaped and crashed containers and pre-warms
+	events *events.Bus
+
+	// provisioned concurrency: replicas kept running regardless of traffic
+	floors       map[string]int
+	provisioning map[string]int // replicas being started by the reaper, the autoscaler or a warmup
+	backoffs     map[string]backoff
+	lastHealth   time.Time
+
+	// host budget and the containers being started against it
+	capacity       CapacityConfig
+	reserved       int
+	reservedMemory int64
+	stoppingMemory int64                 // containers being stopped, still using memory on the host
+	memAvailable   func() (int64, error) // host MemAvailable in mb
+
+	// warm pool of generic runners not yet assigned to any function
+	pool      []*ContainerInfo
+	poolImage string
+	poolSize  int
+	filling   bool
+
+	// containers started in the background, waited for by settle
+	background sync.WaitGroup
 }
 
-// newmanager creates a new reaper manager
-func NewManager(d *docker.Manager) *Manager {
+// newmanager creates a new reaper manager that runs containers on b, usually docker
+func NewManager(b Backend) *Manager {
 	return &Manager{
-		docker:     d,
-		containers: make(map[string]*ContainerInfo),
+		backend:     b,
+		now:         time.Now,
+		logger:      logging.Component("reaper"),
+		containers:  make(map[string][]*ContainerInfo),
+		next:        make(map[string]int),
+		policy:      FixedPolicy{},
+		lastInvoked: make(map[string]time.Time),
+		prewarms:    make(map[string]prewarm),
+		starts:      make(map[string]startCounts),
+		warmups:     make(map[string]*WarmupStats),
+
+		floors:       make(map[string]int),
+		provisioning: make(map[string]int),
+		backoffs:     make(map[string]backoff),
+
+		memAvailable: hostMemAvailable,
 	}
 }
 
-// getcontainer returns the address of a running container if it exists
-func (m *Manager) GetContainer(name string) (string, bool) {
+// setpauseafter enables pausing containers that have been idle for d
+// containers are still stopped once their full timeout passes
+func (m *Manager) SetPauseAfter(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pauseAfter = d
+}
+
+// getcontainer picks a replica of a function to serve a request and returns its address
+// running replicas are used round-robin; a paused one is unpaused only if none is running
+func (m *Manager) GetContainer(ctx context.Context, name string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		info := m.pick(name)
+		if info == nil {
+			return "", false
+		}
+		if info.busy != nil {
+			// being paused or resumed, pick again once that's done
+			m.wait(info)
+			continue
+		}
+		if info.State == StatePaused && !m.resume(ctx, name, info) {
+			// it was dropped, try another replica
+			continue
+		}
+
+		m.acquire(name, info)
+		return info.Address, true
+	}
+}
+
+// acquire marks a container as serving a freshly started replica's first request
+// returns false if no replica of the function has that address
+func (m *Manager) Acquire(name, addr string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, info := range m.containers[name] {
+		if info.Address == addr {
+			m.acquire(name, info)
+			return true
+		}
+	}
+	return false
+}
+
+// release marks the end of a request started with getcontainer or acquire
+func (m *Manager) Release(name, addr string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, info := range m.containers[name] {
+		if info.Address == addr && info.InFlight > 0 {
+			info.InFlight--
+			// idle time counts from the end of the last request
+			info.LastAccessed = m.now()
+			return
+		}
+	}
+}
+
+// acquire records a request routed to a replica
+// callers must hold m.mu
+func (m *Manager) acquire(name string, info *ContainerInfo) {
+	now := m.now()
+	m.accountIdle(name, info, now)
+	info.LastAccessed = now
+	info.ExpiresAt = time.Time{}
+	info.InFlight++
+	info.Served++
+	if info.Served == 1 && info.Origin == OriginWarmup {
+		m.recordWarmupHit(name)
+	}
+}
+
+// replica returns a snapshot of the replica of a function with the given address
+func (m *Manager) Replica(name, addr string) (ContainerInfo, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	info, exists := m.containers[name]
-	if !exists {
-		return "", false
+	for _, info := range m.containers[name] {
+		if info.Address == addr {
+			return *info, true
+		}
+	}
+	return ContainerInfo{}, false
+}
+
+// pick chooses the replica for the next request
+// callers must hold m.mu
+func (m *Manager) pick(name string) *ContainerInfo {
+	replicas := m.containers[name]
+	if len(replicas) == 0 {
+		return nil
+	}
+
+	start := m.next[name]
+	for i := range replicas {
+		info := replicas[(start+i)%len(replicas)]
+		if info.State == StateRunning {
+			m.next[name] = start + i + 1
+			return info
+		}
+	}
+	for i := range replicas {
+		if info := replicas[(start+i)%len(replicas)]; info.State == StatePaused {
+			return info
+		}
+	}
+	// only draining replicas left
+	return nil
+}
+
+// resume unpauses a replica, dropping it if docker can't
+// callers must hold m.mu, it is released during the docker call and requests for the replica wait
+func (m *Manager) resume(ctx context.Context, name string, info *ContainerInfo) bool {
+	done := make(chan struct{})
+	info.busy = done
+	m.mu.Unlock()
+	start := time.Now()
+	err := m.backend.UnpauseContainer(context.Background(), info.ID)
+	m.mu.Lock()
+	info.busy = nil
+	close(done)
+
+	if m.find(name, info.ID) == nil {
+		// stopped in the meantime
+		return false
+	}
+	if err != nil {
+		m.logger.ErrorContext(ctx, "failed to unpause container", "function", name, "container", info.ID[:12], "error", err)
+		// treat it as gone so the caller cold starts a fresh one
+		m.stopContainer(name, info)
+		m.remove(name, info)
+		evictions.WithLabelValues("unpause_failed").Inc()
+		m.events.Publish(events.Event{Type: events.ContainerReaped, Function: name, Version: info.Version, Container: info.ID[:12], Reason: "unpause_failed"})
+		m.updateStateMetrics()
+		return false
+	}
+	resumeDuration.Observe(time.Since(start).Seconds())
+	if info.State == StateDraining {
+		// drained in the meantime, it's unpaused for cleanup to stop
+		return false
+	}
+
+	info.State = StateRunning
+	m.updateStateMetrics()
+	m.logger.DebugContext(ctx, "resumed container", "function", name, "container", info.ID[:12], "duration", time.Since(start))
+	return true
+}
+
+// wait blocks until the pause or unpause running on a replica is done
+// callers must hold m.mu, it is released while waiting
+func (m *Manager) wait(info *ContainerInfo) {
+	done := info.busy
+	m.mu.Unlock()
+	<-done
+	m.mu.Lock()
+}
+
+// inbackground runs a docker call on a replica without holding m.mu
+// the replica is busy until then runs, with m.mu held and the call's error:
+// requests wait for it and cleanup leaves it alone
+// callers must hold m.mu
+func (m *Manager) inBackground(info *ContainerInfo, call func(ctx context.Context, id string) error, then func(error)) {
+	done := make(chan struct{})
+	info.busy = done
+	m.background.Add(1)
+	go func() {
+		defer m.background.Done()
+		err := call(context.Background(), info.ID)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		info.busy = nil
+		close(done)
+		then(err)
+	}()
+}
+
+// stopcontainer stops the container of a replica in the background, without holding m.mu
+// its memory counts as free for the host memory check until the stop is done
+// callers must hold m.mu and drop the replica from the tracker
+func (m *Manager) stopContainer(name string, info *ContainerInfo) {
+	id, paused, busy := info.ID, info.State == StatePaused, info.busy
+	memory := info.MemoryLimit
+	if memory <= 0 {
+		memory = defaultMemoryMB
+	}
+	m.stoppingMemory += memory
+	m.background.Add(1)
+	go func() {
+		defer m.background.Done()
+		defer func() {
+			m.mu.Lock()
+			m.stoppingMemory -= memory
+			m.mu.Unlock()
+		}()
+		if busy != nil {
+			// a pause may be finishing, leaving the container frozen
+			<-busy
+			paused = true
+		}
+		// we use a background context because cleanup shouldn't be cancelled by request context
+		ctx := context.Background()
+		if paused {
+			// a frozen process can't handle the stop signal
+			m.backend.UnpauseContainer(ctx, id)
+		}
+		if err := m.backend.StopContainer(ctx, id); err != nil {
+			m.logger.Error("failed to stop container", "function", name, "container", id[:12], "error", err)
+		}
+	}()
+}
+
+// register adds a new container to the tracker as a replica of a function
+// id and address are required; the state, access time and served count are set here
+func (m *Manager) Register(ctx context.Context, name string, info ContainerInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if info.Timeout == 0 {
+		info.Timeout = 10 * time.Second // default to 10s if not specified
+	}
+	if info.Origin == "" {
+		info.Origin = OriginRequest
 	}
-	return info.Address, true
+	info.State = StateRunning
+	info.LastAccessed = m.now()
+	info.StartedAt = info.LastAccessed
+	info.Served = 0
+
+	m.containers[name] = append(m.containers[name], &info)
+	m.updateStateMetrics()
+	m.events.Publish(events.Event{Type: events.ContainerRegistered, Function: name, Version: info.Version, Container: info.ID[:12], Origin: info.Origin, Replicas: len(m.containers[name])})
+	m.logger.InfoContext(ctx, "registered container", "function", name, "container", info.ID[:12], "timeout", info.Timeout, "origin", info.Origin, "replicas", len(m.containers[name]))
+}
+
+// setevents sets where container lifecycle events are published
+func (m *Manager) SetEvents(b *events.Bus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = b
+}
+
+// replicas returns the number of containers of a function accepting requests
+func (m *Manager) Replicas(name string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.active(name)
 }
 
-// register adds a new container to the tracker
-func (m *Manager) Register(name, id, address string, timeoutSeconds int) {
+// reservewarmup counts the replicas a warmup of a function needs, extending the life of the running ones
+// replicas being started, by another warmup or the reaper, count toward want
+// the missing ones are counted as being started until the caller reports each with warmupdone
+func (m *Manager) ReserveWarmup(name string, want int) (running, starting, missing int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	timeout := time.Duration(timeoutSeconds) * time.Second
-	if timeout == 0 {
-		timeout = 10 * time.Second // default to 10s if not specified
+	m.touch(name)
+	running = min(m.active(name), want)
+	starting = min(m.provisioning[name], want-running)
+	missing = want - running - starting
+	m.provisioning[name] += missing
+	return running, starting, missing
+}
+
+// warmupdone reports that one replica reserved with reservewarmup started or failed
+func (m *Manager) WarmupDone(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.provisioning[name]--
+}
+
+// containers returns a copy of the replicas of a function
+func (m *Manager) Containers(name string) []ContainerInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]ContainerInfo, 0, len(m.containers[name]))
+	for _, info := range m.containers[name] {
+		out = append(out, *info)
 	}
+	return out
+}
 
-	m.containers[name] = &ContainerInfo{
-		ID:           id,
-		Address:      address,
-		LastAccessed: time.Now(),
-		Timeout:      timeout,
+// allcontainers returns a copy of the replicas of every function
+func (m *Manager) AllContainers() map[string][]ContainerInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make(map[string][]ContainerInfo, len(m.containers))
+	for name, replicas := range m.containers {
+		for _, info := range replicas {
+			out[name] = append(out[name], *info)
+		}
 	}
-	fmt.Printf("[reaper] registered container for %s (id: %s, timeout: %s)\n", name, id[:12], timeout)
+	return out
 }
 
-// touch updates the last accessed time for a container
+// statstargets lists every tracked replica for the docker stats collector
+func (m *Manager) StatsTargets() []docker.StatsTarget {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []docker.StatsTarget
+	for name, replicas := range m.containers {
+		for _, info := range replicas {
+			out = append(out, docker.StatsTarget{ID: info.ID, Function: name, MemoryLimit: info.MemoryLimit})
+		}
+	}
+	return out
+}
+
+// touch updates the last accessed time of every replica of a function
 func (m *Manager) Touch(name string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.touch(name)
+}
 
-	if info, exists := m.containers[name]; exists {
-		info.LastAccessed = time.Now()
+// touch resets the idle time of every replica of a function
+// callers must hold m.mu
+func (m *Manager) touch(name string) {
+	now := m.now()
+	for _, info := range m.containers[name] {
+		m.accountIdle(name, info, now)
+		info.LastAccessed = now
+		info.ExpiresAt = time.Time{}
 	}
 }
 
+// find returns the replica with the given container id
+// callers must hold m.mu
+func (m *Manager) find(name, id string) *ContainerInfo {
+	for _, info := range m.containers[name] {
+		if info.ID == id {
+			return info
+		}
+	}
+	return nil
+}
+
+// remove drops a replica from the tracker (it does not stop the container)
+// callers must hold m.mu
+func (m *Manager) remove(name string, target *ContainerInfo) {
+	replicas := m.containers[name]
+	for i, info := range replicas {
+		if info == target {
+			replicas = append(replicas[:i:i], replicas[i+1:]...)
+			break
+		}
+	}
+	if len(replicas) == 0 {
+		delete(m.containers, name)
+		delete(m.next, name)
+		return
+	}
+	m.containers[name] = replicas
+}
+
 // start runs the background cleanup loop
 func (m *Manager) Start(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	fmt.Println("[reaper] background job started")
+	m.logger.InfoContext(ctx, "background job started")
 
 	for {
 		select {
 		case <-ctx.Done():
-			fmt.Println("[reaper] stopping background job")
+			m.logger.InfoContext(ctx, "stopping background job")
 			return
 		case <-ticker.C:
-			m.cleanup()
+			m.Tick(ctx)
+			if time.Since(m.lastHealth) >= healthInterval {
+				m.lastHealth = time.Now()
+				m.checkHealth(ctx)
+			}
+			go m.topUpPool(ctx)
 		}
 	}
 }
 
+// tick runs one pass of the cleanup loop: idle and drained replicas are stopped,
+// replicas past their limits recycled, and pre-warms and minimum instances started
+// health checks and the warm pool probe real containers and are left to start
+func (m *Manager) Tick(ctx context.Context) {
+	m.cleanup()
+	m.recycle(ctx)
+	m.runPrewarms(ctx)
+	m.ensureFloors(ctx)
+}
+
+// settle waits for the containers tick started in the background
+// the simulator calls it so every start lands before its virtual clock moves on
+func (m *Manager) Settle() {
+	m.background.Wait()
+}
+
 func (m *Manager) cleanup() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	now := time.Now()
-	for name, info := range m.containers {
-		if now.Sub(info.LastAccessed) > info.Timeout {
-			fmt.Printf("[reaper] container for %s idle for %v. stopping...\n", name, now.Sub(info.LastAccessed))
-			
-			// stop container in docker
-			// we use a background context because cleanup shouldn't be cancelled by request context
-			if err := m.docker.StopContainer(context.Background(), info.ID); err != nil {
-				log.Printf("error stopping container %s: %v", info.ID, err)
+	now := m.now()
+	for name, replicas := range m.containers {
+		for _, info := range replicas {
+			// meter idle time as it accrues, replicas that never get a request are billed too
+			m.accountIdle(name, info, now)
+			m.cleanupReplica(name, info, now)
+		}
+	}
+	m.updateStateMetrics()
+}
+
+// cleanupreplica stops or pauses one replica depending on how long it has been idle
+// callers must hold m.mu
+func (m *Manager) cleanupReplica(name string, info *ContainerInfo, now time.Time) {
+	if info.busy != nil {
+		// being paused or resumed
+		return
+	}
+	if info.State == StateDraining {
+		if info.InFlight == 0 {
+			m.logger.Info("container drained, stopping", "function", name, "container", info.ID[:12])
+			m.stopReplica(name, info, now, "drained")
+		}
+		return
+	}
+	if m.atFloor(name) || info.InFlight > 0 {
+		// provisioned and busy replicas are never paused or stopped
+		return
+	}
+
+	idle := now.Sub(info.LastAccessed)
+	deadline, window := m.deadline(name, info)
+
+	if now.After(deadline) {
+		m.logger.Info("container idle, stopping", "function", name, "container", info.ID[:12], "idle", idle, "policy", m.policy.Name())
+		m.stopReplica(name, info, now, "idle")
+		containersStopped.Inc()
+
+		// the policy expects the next request later: start a fresh container just before it
+		if window.PreWarm > 0 && info.ExpiresAt.IsZero() && m.active(name) == 0 {
+			m.schedulePrewarm(name, window)
+		}
+		return
+	}
+
+	// intermediate tier: pause so the container stops using cpu but resumes in milliseconds
+	if m.pauseAfter > 0 && info.LastAccessed.Add(m.pauseAfter).Before(deadline) && info.State == StateRunning && idle > m.pauseAfter {
+		m.inBackground(info, m.backend.PauseContainer, func(err error) {
+			if err != nil {
+				m.logger.Error("failed to pause container", "function", name, "container", info.ID[:12], "error", err)
+				return
 			}
+			if info.State == StateDraining {
+				// drained in the meantime, cleanup can't stop it frozen
+				m.inBackground(info, m.backend.UnpauseContainer, func(error) {})
+				return
+			}
+			info.State = StatePaused
+			m.updateStateMetrics()
+			m.logger.Info("container idle, paused", "function", name, "container", info.ID[:12], "idle", idle)
+		})
+	}
+}
+
+// stopreplica stops an idle replica and drops it from the tracker
+// reason is recorded in reaper_evictions_total (idle, drained, autoscale, capacity)
+// callers must hold m.mu
+func (m *Manager) stopReplica(name string, info *ContainerInfo, now time.Time, reason string) {
+	m.stopContainer(name, info)
+	evictions.WithLabelValues(reason).Inc()
+	m.events.Publish(events.Event{Type: events.ContainerReaped, Function: name, Version: info.Version, Container: info.ID[:12], Reason: reason})
+	m.accountIdle(name, info, now)
+	if info.Origin == OriginWarmup && info.Served == 0 {
+		m.recordWarmupWasted(name)
+	}
+
+	// remove from map
+	m.remove(name, info)
+}
 
-			// remove from map
-			delete(m.containers, name)
+// updatestatemetrics refreshes the per-state container gauges
+// callers must hold m.mu
+func (m *Manager) updateStateMetrics() {
+	counts := map[string]int{StateRunning: 0, StatePaused: 0, StateDraining: 0}
+	for _, replicas := range m.containers {
+		for _, info := range replicas {
+			counts[info.State]++
 		}
 	}
-}
\ No newline at end of file
+	for state, n := range counts {
+		containersByState.WithLabelValues(state).Set(float64(n))
+	}
+
+	activeContainers.Reset()
+	for name := range m.containers {
+		activeContainers.WithLabelValues(name).Set(float64(m.active(name)))
+	}
+}
@@ -0,0 +1,110 @@
+package reaper
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	containersByState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "reaper_containers",
+			Help: "Tracked function containers by state (running, paused, draining)",
+		},
+		[]string{"state"},
+	)
+	containersStopped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "reaper_containers_stopped_total",
+			Help: "Function containers stopped after their idle timeout",
+		},
+	)
+	activeContainers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "function_active_containers",
+			Help: "Replicas accepting requests per function (running or paused)",
+		},
+		[]string{"function"},
+	)
+	evictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reaper_evictions_total",
+			Help: "Function containers stopped by the gateway, by reason (idle, drained, autoscale, capacity, unhealthy, unpause_failed)",
+		},
+		[]string{"reason"},
+	)
+	containersCrashed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reaper_containers_crashed_total",
+			Help: "Function containers removed after failing health checks",
+		},
+		[]string{"function"},
+	)
+	containersRecycled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reaper_containers_recycled_total",
+			Help: "Replicas retired after reaching their max lifetime or max requests, by reason (lifetime, requests)",
+		},
+		[]string{"function", "reason"},
+	)
+	capacityEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reaper_capacity_evictions_total",
+			Help: "Idle containers stopped early to make room for a new start",
+		},
+		[]string{"function"},
+	)
+	capacityRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reaper_capacity_rejections_total",
+			Help: "Container starts refused because a host limit (containers, memory, host_memory) was reached and nothing could be evicted",
+		},
+		[]string{"limit"},
+	)
+	invocationsByStart = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reaper_invocations_total",
+			Help: "Invocations seen by the reaper, by keep-alive policy and start type (cold, warm)",
+		},
+		[]string{"function", "policy", "start"},
+	)
+	coldStartRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "reaper_cold_start_ratio",
+			Help: "Fraction of invocations that found no container, since gateway start",
+		},
+		[]string{"function", "policy"},
+	)
+	wastedMemory = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reaper_wasted_memory_mb_seconds_total",
+			Help: "Memory held by idle containers (mb x seconds), by keep-alive policy",
+		},
+		[]string{"function", "policy"},
+	)
+	windowSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "reaper_keepalive_window_seconds",
+			Help: "Current keep-alive policy windows per function (prewarm, keepalive)",
+		},
+		[]string{"function", "window"},
+	)
+	warmupOutcomes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "warmup_outcomes_total",
+			Help: "Warmup effectiveness: hit (warmed container served a request), wasted (reaped unused), missed (cold start with nothing warmed)",
+		},
+		[]string{"function", "outcome"},
+	)
+	resumeDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "reaper_resume_duration_seconds",
+			Help:    "Time taken to unpause a paused container on its next request",
+			Buckets: []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25},
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(containersByState, containersStopped, containersCrashed, resumeDuration)
+	prometheus.MustRegister(capacityEvictions, capacityRejections, containersRecycled)
+	prometheus.MustRegister(activeContainers, evictions)
+	prometheus.MustRegister(invocationsByStart, coldStartRatio, wastedMemory, windowSeconds, warmupOutcomes)
+}
@@ -0,0 +1,153 @@
+package reaper
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// window is a keep-alive decision for a function
+// a zero prewarm keeps the container loaded for keepalive after its last request
+// otherwise the container is unloaded right away and started again prewarm after
+// the last request, then kept for keepalive
+type Window struct {
+	PreWarm   time.Duration
+	KeepAlive time.Duration
+}
+
+// keepalivepolicy decides how long idle containers are kept
+type KeepAlivePolicy interface {
+	// name identifies the policy in logs and metrics
+	Name() string
+	// observe records the idle time that preceded an invocation of a function
+	Observe(function string, idle time.Duration)
+	// window returns the keep-alive decision for a function
+	// configured is the function's own timeout from the registry
+	Window(function string, configured time.Duration) Window
+}
+
+// fixedpolicy keeps every container for its configured timeout
+type FixedPolicy struct{}
+
+func (FixedPolicy) Name() string                  { return "fixed" }
+func (FixedPolicy) Observe(string, time.Duration) {}
+func (FixedPolicy) Window(_ string, configured time.Duration) Window {
+	return Window{KeepAlive: configured}
+}
+
+// hybridconfig tunes the hybrid histogram policy
+type HybridConfig struct {
+	BinWidth       time.Duration // width of one histogram bin
+	Bins           int           // idle times beyond BinWidth*Bins are out of range
+	HeadPercentile float64       // idle-time percentile used as the pre-warm window
+	TailPercentile float64       // idle-time percentile that ends the keep-alive window
+	Margin         float64       // fraction the windows are widened by on each side
+	MinPreWarm     time.Duration // shorter pre-warm windows keep the container loaded instead
+	MinSamples     int           // below this many observations the fixed policy is used
+	MaxOutOfRange  float64       // fall back to fixed if more observations than this are out of range
+}
+
+// defaulthybridconfig returns settings suited to second-scale idle times
+func DefaultHybridConfig() HybridConfig {
+	return HybridConfig{
+		BinWidth:       time.Second,
+		Bins:           600,
+		HeadPercentile: 5,
+		TailPercentile: 99,
+		Margin:         0.1,
+		MinPreWarm:     10 * time.Second,
+		MinSamples:     10,
+		MaxOutOfRange:  0.5,
+	}
+}
+
+// hybridpolicy learns each function's idle-time distribution and keeps
+// containers only around the times the next request is likely to arrive
+// (the hybrid histogram policy from "Serverless in the Wild", shahrad et al. 2020)
+type HybridPolicy struct {
+	config   HybridConfig
+	fallback FixedPolicy
+
+	mu    sync.Mutex
+	hists map[string]*idleHistogram
+}
+
+type idleHistogram struct {
+	bins       []int
+	total      int
+	outOfRange int
+}
+
+// newhybridpolicy creates a hybrid histogram policy
+func NewHybridPolicy(config HybridConfig) *HybridPolicy {
+	return &HybridPolicy{
+		config: config,
+		hists:  make(map[string]*idleHistogram),
+	}
+}
+
+func (p *HybridPolicy) Name() string { return "hybrid" }
+
+func (p *HybridPolicy) Observe(function string, idle time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h, ok := p.hists[function]
+	if !ok {
+		h = &idleHistogram{bins: make([]int, p.config.Bins)}
+		p.hists[function] = h
+	}
+
+	h.total++
+	bin := int(idle / p.config.BinWidth)
+	if bin >= len(h.bins) {
+		h.outOfRange++
+		return
+	}
+	h.bins[bin]++
+}
+
+func (p *HybridPolicy) Window(function string, configured time.Duration) Window {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h, ok := p.hists[function]
+	if !ok || h.total < p.config.MinSamples {
+		// too sparse to learn from yet
+		return p.fallback.Window(function, configured)
+	}
+	if float64(h.outOfRange)/float64(h.total) > p.config.MaxOutOfRange {
+		// idle times are mostly longer than the histogram covers, a pattern can't be
+		// seen at this resolution
+		return p.fallback.Window(function, configured)
+	}
+
+	inRange := h.total - h.outOfRange
+	head := h.percentileBin(p.config.HeadPercentile, inRange)
+	tail := h.percentileBin(p.config.TailPercentile, inRange) + 1
+
+	width := float64(p.config.BinWidth)
+	preWarm := time.Duration(float64(head) * (1 - p.config.Margin) * width)
+	end := time.Duration(math.Ceil(float64(tail)*(1+p.config.Margin)) * width)
+	if preWarm < p.config.MinPreWarm {
+		// unloading and reloading this soon would cost more than it saves
+		return Window{KeepAlive: end}
+	}
+	return Window{PreWarm: preWarm, KeepAlive: end - preWarm}
+}
+
+// percentilebin returns the bin holding the given percentile of n in-range observations
+func (h *idleHistogram) percentileBin(percentile float64, n int) int {
+	target := int(math.Ceil(percentile / 100 * float64(n)))
+	if target < 1 {
+		target = 1
+	}
+	seen := 0
+	for i, count := range h.bins {
+		seen += count
+		if seen >= target {
+			return i
+		}
+	}
+	return len(h.bins) - 1
+}
@@ -0,0 +1,65 @@
+package reaper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHybridPolicyWindow(t *testing.T) {
+	const configured = 5 * time.Minute
+	observe := func(p *HybridPolicy, idle time.Duration, n int) {
+		for i := 0; i < n; i++ {
+			p.Observe("fn", idle)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		observe func(p *HybridPolicy)
+		want    Window
+	}{
+		{
+			name:    "too few samples fall back to the configured timeout",
+			observe: func(p *HybridPolicy) { observe(p, time.Minute, 9) },
+			want:    Window{KeepAlive: configured},
+		},
+		{
+			name: "mostly out of range falls back to the configured timeout",
+			observe: func(p *HybridPolicy) {
+				observe(p, time.Hour, 6)
+				observe(p, time.Minute, 4)
+			},
+			want: Window{KeepAlive: configured},
+		},
+		{
+			// head bin 60 narrowed by the 10% margin, tail bin 61 widened by it and rounded up
+			name:    "long regular gaps unload and pre-warm",
+			observe: func(p *HybridPolicy) { observe(p, time.Minute, 20) },
+			want:    Window{PreWarm: 54 * time.Second, KeepAlive: 68*time.Second - 54*time.Second},
+		},
+		{
+			name:    "a pre-warm shorter than the minimum keeps the container loaded",
+			observe: func(p *HybridPolicy) { observe(p, 5*time.Second, 20) },
+			want:    Window{KeepAlive: 7 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewHybridPolicy(DefaultHybridConfig())
+			tt.observe(p)
+			if got := p.Window("fn", configured); got != tt.want {
+				t.Errorf("Window = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentileBin(t *testing.T) {
+	// 10 observations: 2 in bin 1, 3 in bin 3, 5 in bin 4
+	h := &idleHistogram{bins: []int{0, 2, 0, 3, 5}}
+	for percentile, want := range map[float64]int{0: 1, 20: 1, 21: 3, 50: 3, 51: 4, 100: 4} {
+		if got := h.percentileBin(percentile, 10); got != want {
+			t.Errorf("percentileBin(%v) = %d, want %d", percentile, got, want)
+		}
+	}
+}
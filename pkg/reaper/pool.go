@@ -0,0 +1,100 @@
+package reaper
+
+import (
+	"context"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/docker"
+	"github.com/nikhi/nanolambda/pkg/runner"
+)
+
+// poolname is the function name used for unassigned pooled containers
+const poolName = "pool"
+
+// enablepool keeps size generic runtime containers started from image
+// they are handed to functions on their first invocation instead of a cold start
+func (m *Manager) EnablePool(image string, size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.poolImage = image
+	m.poolSize = size
+}
+
+// claim removes an unassigned container from the pool
+// the caller loads code into it and registers it for a function
+func (m *Manager) Claim() (*ContainerInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.pool) == 0 {
+		return nil, false
+	}
+	info := m.pool[0]
+	m.pool = m.pool[1:]
+	return info, true
+}
+
+// poolstats returns the number of idle pooled containers and the configured pool size
+func (m *Manager) PoolStats() (int, int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.pool), m.poolSize
+}
+
+// topuppool starts pooled containers until the pool is back at its configured size
+// only one top-up runs at a time
+func (m *Manager) topUpPool(ctx context.Context) {
+	m.mu.Lock()
+	if m.filling || len(m.pool) >= m.poolSize {
+		m.mu.Unlock()
+		return
+	}
+	m.filling = true
+	image := m.poolImage
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		m.filling = false
+		m.mu.Unlock()
+	}()
+
+	for {
+		m.mu.RLock()
+		missing := m.poolSize - len(m.pool)
+		m.mu.RUnlock()
+		if missing <= 0 || ctx.Err() != nil {
+			return
+		}
+
+		// the pool only fills spare capacity, it never evicts function containers
+		release, err := m.reserve(ctx, defaultMemoryMB, false)
+		if err != nil {
+			return
+		}
+
+		addr, id, err := m.backend.StartContainer(ctx, docker.ContainerSpec{Image: image, Name: poolName})
+		if err != nil {
+			release()
+			m.logger.ErrorContext(ctx, "failed to start pooled container", "error", err)
+			return
+		}
+		if !runner.WaitReady(ctx, addr, 50, 100*time.Millisecond) {
+			release()
+			m.logger.WarnContext(ctx, "pooled container never became ready, discarding", "container", id[:12])
+			m.backend.StopContainer(context.Background(), id)
+			return
+		}
+
+		m.mu.Lock()
+		m.pool = append(m.pool, &ContainerInfo{
+			ID:           id,
+			Address:      addr,
+			LastAccessed: m.now(),
+		})
+		m.mu.Unlock()
+		release()
+		m.logger.InfoContext(ctx, "pooled container ready", "container", id[:12])
+	}
+}
@@ -0,0 +1,172 @@
+package reaper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/events"
+	"github.com/nikhi/nanolambda/pkg/runner"
+)
+
+// healthinterval is how often running replicas are probed
+const healthInterval = 5 * time.Second
+
+// healthfailures is how many failed probes in a row mark a replica as crashed
+const healthFailures = 2
+
+// a function whose replicas fail to start is retried after minBackoff, doubling up to maxBackoff
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// backoff holds off starting replicas of a function after a failed start
+type backoff struct {
+	until time.Time
+	delay time.Duration
+}
+
+// setmininstances replaces the per-function replica floors
+// functions missing from floors go back to scaling to zero
+func (m *Manager) SetMinInstances(floors map[string]int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, n := range floors {
+		if n != m.floors[name] {
+			m.logger.Info("min instances changed", "function", name, "min_instances", n)
+		}
+	}
+	for name := range m.floors {
+		if _, ok := floors[name]; !ok {
+			m.logger.Info("min instances changed", "function", name, "min_instances", 0)
+		}
+	}
+
+	m.floors = make(map[string]int)
+	for name, n := range floors {
+		if n > 0 {
+			m.floors[name] = n
+		}
+	}
+}
+
+// atfloor reports whether a function has no replicas to spare above its minimum
+// callers must hold m.mu
+func (m *Manager) atFloor(name string) bool {
+	floor := m.floors[name]
+	return floor > 0 && m.active(name) <= floor
+}
+
+// ensurefloors starts replicas for functions running below their minimum
+// a failed start holds off the function for a while, backing off exponentially
+func (m *Manager) ensureFloors(ctx context.Context) {
+	m.mu.Lock()
+	launch := m.launcher
+	if launch == nil {
+		m.mu.Unlock()
+		return
+	}
+	now := m.now()
+	missing := make(map[string]int)
+	for name, floor := range m.floors {
+		if now.Before(m.backoffs[name].until) {
+			continue
+		}
+		if n := floor - m.active(name) - m.provisioning[name]; n > 0 {
+			missing[name] = n
+			m.provisioning[name] += n
+		}
+	}
+	m.mu.Unlock()
+
+	for name, n := range missing {
+		m.logger.InfoContext(ctx, "below min instances, starting replicas", "function", name, "missing", n)
+		m.launchReplicas(ctx, launch, name, n, OriginProvisioned)
+	}
+}
+
+// launchreplicas starts n replicas of a function in the background
+// callers must already have counted them in m.provisioning, without holding m.mu here
+// a failed start holds off ensurefloors for the function, backing off exponentially
+func (m *Manager) launchReplicas(ctx context.Context, launch Launcher, name string, n int, origin string) {
+	for i := 0; i < n; i++ {
+		m.background.Add(1)
+		go func() {
+			defer m.background.Done()
+			err := launch(ctx, name, origin)
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.provisioning[name]--
+			if err == nil {
+				delete(m.backoffs, name)
+				return
+			}
+			b := m.backoffs[name]
+			b.delay = min(max(2*b.delay, minBackoff), maxBackoff)
+			b.until = m.now().Add(b.delay)
+			m.backoffs[name] = b
+			m.logger.WarnContext(ctx, "failed to start replica", "function", name, "origin", origin, "error", err, "retry_in", b.delay)
+		}()
+	}
+}
+
+// checkhealth probes every running replica and drops the ones that stopped answering
+// dropped replicas of functions with a minimum are replaced by ensurefloors
+func (m *Manager) checkHealth(ctx context.Context) {
+	m.mu.RLock()
+	type target struct {
+		name string
+		info *ContainerInfo
+	}
+	var targets []target
+	for name, replicas := range m.containers {
+		for _, info := range replicas {
+			// paused containers can't answer
+			if info.State == StateRunning && info.busy == nil {
+				targets = append(targets, target{name, info})
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	healthy := make([]bool, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			healthy[i] = runner.WaitReady(probeCtx, addr, 1, 0)
+		}(i, t.info.Address)
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, t := range targets {
+		// it may have been stopped or paused while probing
+		if m.find(t.name, t.info.ID) == nil || t.info.State != StateRunning || t.info.busy != nil {
+			continue
+		}
+		if healthy[i] {
+			t.info.failures = 0
+			continue
+		}
+		t.info.failures++
+		if t.info.failures < healthFailures {
+			continue
+		}
+
+		m.logger.WarnContext(ctx, "container failed health checks, removing", "function", t.name, "container", t.info.ID[:12], "failures", t.info.failures)
+		m.stopContainer(t.name, t.info)
+		m.remove(t.name, t.info)
+		containersCrashed.WithLabelValues(t.name).Inc()
+		evictions.WithLabelValues("unhealthy").Inc()
+		m.events.Publish(events.Event{Type: events.ContainerCrashed, Function: t.name, Version: t.info.Version, Container: t.info.ID[:12], Reason: "failed health checks"})
+	}
+	m.updateStateMetrics()
+}
@@ -0,0 +1,117 @@
+package reaper
+
+import (
+	"context"
+	"time"
+)
+
+// recycletraffic is how recently a replica must have served a request for its
+// replacement to be started before it retires
+const recycleTraffic = 30 * time.Second
+
+// recyclereason returns why a replica has reached its lifetime or request limit, or ""
+func recycleReason(info *ContainerInfo, now time.Time) string {
+	if info.MaxRequests > 0 && info.Served >= info.MaxRequests {
+		return "requests"
+	}
+	if info.MaxLifetime > 0 && now.Sub(info.StartedAt) >= info.MaxLifetime {
+		return "lifetime"
+	}
+	return ""
+}
+
+// active returns the replicas of a function that accept new requests
+// callers must hold m.mu
+func (m *Manager) active(name string) int {
+	n := 0
+	for _, info := range m.containers[name] {
+		if info.State != StateDraining {
+			n++
+		}
+	}
+	return n
+}
+
+// recycle retires replicas that reached their max lifetime or max requests
+// a replica that still has traffic keeps serving until its replacement is registered,
+// unless another replica can take its requests in the meantime
+func (m *Manager) recycle(ctx context.Context) {
+	m.mu.Lock()
+	launch := m.launcher
+	now := m.now()
+	replacements := make(map[string]int)
+	for name, replicas := range m.containers {
+		for _, info := range replicas {
+			if info.State == StateDraining || info.replacing {
+				continue
+			}
+			reason := recycleReason(info, now)
+			if reason == "" {
+				continue
+			}
+			busy := info.InFlight > 0 || now.Sub(info.LastAccessed) < recycleTraffic || m.atFloor(name)
+			if !busy || launch == nil {
+				m.logger.InfoContext(ctx, "container reached its limit, draining", "function", name, "container", info.ID[:12], "limit", reason)
+				m.drain(name, info)
+				containersRecycled.WithLabelValues(name, reason).Inc()
+				continue
+			}
+
+			if m.active(name) > 1 {
+				// the other replicas cover for it while the replacement boots
+				m.logger.InfoContext(ctx, "container reached its limit, draining and replacing", "function", name, "container", info.ID[:12], "limit", reason)
+				m.drain(name, info)
+				containersRecycled.WithLabelValues(name, reason).Inc()
+				m.provisioning[name]++
+				replacements[name]++
+				continue
+			}
+
+			m.logger.InfoContext(ctx, "container reached its limit, replacing before draining", "function", name, "container", info.ID[:12], "limit", reason)
+			info.replacing = true
+			m.provisioning[name]++
+			m.background.Add(1)
+			go m.replace(ctx, launch, name, info, reason)
+		}
+	}
+	m.mu.Unlock()
+
+	for name, n := range replacements {
+		m.launchReplicas(ctx, launch, name, n, OriginRecycle)
+	}
+}
+
+// replace starts a replacement for a replica and drains the old one once it is registered
+func (m *Manager) replace(ctx context.Context, launch Launcher, name string, old *ContainerInfo, reason string) {
+	defer m.background.Done()
+	err := launch(ctx, name, OriginRecycle)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.provisioning[name]--
+	old.replacing = false
+	if err != nil {
+		// keep the old replica serving, the next tick tries again
+		m.logger.WarnContext(ctx, "replacement failed", "function", name, "error", err)
+		return
+	}
+	if m.find(name, old.ID) != nil {
+		m.drain(name, old)
+		containersRecycled.WithLabelValues(name, reason).Inc()
+	}
+}
+
+// drain stops routing requests to a replica, cleanup stops it once its in-flight requests finish
+// callers must hold m.mu
+func (m *Manager) drain(name string, info *ContainerInfo) {
+	if info.State == StatePaused && info.busy == nil {
+		// cleanup waits for the unpause before stopping it
+		m.inBackground(info, m.backend.UnpauseContainer, func(err error) {
+			if err != nil {
+				m.logger.Error("failed to unpause container", "function", name, "container", info.ID[:12], "error", err)
+			}
+		})
+	}
+	info.State = StateDraining
+	m.updateStateMetrics()
+}
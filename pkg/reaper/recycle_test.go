@@ -0,0 +1,46 @@
+package reaper
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRecycleReason(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	hourOld := now.Add(-time.Hour)
+
+	if got := recycleReason(&ContainerInfo{Served: 1000, StartedAt: now.Add(-24 * time.Hour)}, now); got != "" {
+		t.Errorf("without limits: %q, want none", got)
+	}
+	if got := recycleReason(&ContainerInfo{MaxRequests: 100, Served: 100, StartedAt: now}, now); got != "requests" {
+		t.Errorf("at max_requests: %q, want requests", got)
+	}
+	if got := recycleReason(&ContainerInfo{MaxLifetime: time.Hour, StartedAt: hourOld.Add(time.Second)}, now); got != "" {
+		t.Errorf("a second before max_lifetime: %q, want none", got)
+	}
+	if got := recycleReason(&ContainerInfo{MaxLifetime: time.Hour, StartedAt: hourOld}, now); got != "lifetime" {
+		t.Errorf("at max_lifetime: %q, want lifetime", got)
+	}
+}
+
+func TestRecycleDrainsIdleReplica(t *testing.T) {
+	m := NewManager(nil)
+	ctx := context.Background()
+	m.Register(ctx, "fn", ContainerInfo{ID: "old-container-id", Address: "127.0.0.1:1", MaxRequests: 10})
+	info := m.containers["fn"][0]
+	info.Served = 10
+	info.LastAccessed = time.Now().Add(-time.Minute)
+
+	m.recycle(ctx)
+
+	if info.State != StateDraining {
+		t.Fatalf("state = %s, want %s", info.State, StateDraining)
+	}
+	if n := m.Replicas("fn"); n != 0 {
+		t.Errorf("Replicas = %d, want 0 (draining replicas take no requests)", n)
+	}
+	if _, ok := m.GetContainer(ctx, "fn"); ok {
+		t.Error("GetContainer routed a request to a draining replica")
+	}
+}
@@ -0,0 +1,56 @@
+package reaper
+
+// warmupstats measures how useful warmups were for a function
+type WarmupStats struct {
+	Hits   int `json:"hits"`   // warmed containers that served at least one request
+	Wasted int `json:"wasted"` // warmed containers reaped without serving a request
+	Missed int `json:"missed"` // cold starts with no container warmed in advance
+	Active int `json:"active"` // warmed containers still waiting for their first request
+}
+
+// warmupstats returns the warmup counters for every function seen so far
+func (m *Manager) WarmupStats() map[string]WarmupStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make(map[string]WarmupStats, len(m.warmups))
+	for name, stats := range m.warmups {
+		out[name] = *stats
+	}
+	for name, replicas := range m.containers {
+		for _, info := range replicas {
+			if info.Origin == OriginWarmup && info.Served == 0 {
+				stats := out[name]
+				stats.Active++
+				out[name] = stats
+			}
+		}
+	}
+	return out
+}
+
+// warmupstatsfor returns the counters of a function, creating them if needed
+// callers must hold m.mu
+func (m *Manager) warmupStatsFor(name string) *WarmupStats {
+	stats, ok := m.warmups[name]
+	if !ok {
+		stats = &WarmupStats{}
+		m.warmups[name] = stats
+	}
+	return stats
+}
+
+func (m *Manager) recordWarmupHit(name string) {
+	m.warmupStatsFor(name).Hits++
+	warmupOutcomes.WithLabelValues(name, "hit").Inc()
+}
+
+func (m *Manager) recordWarmupWasted(name string) {
+	m.warmupStatsFor(name).Wasted++
+	warmupOutcomes.WithLabelValues(name, "wasted").Inc()
+}
+
+func (m *Manager) recordWarmupMissed(name string) {
+	m.warmupStatsFor(name).Missed++
+	warmupOutcomes.WithLabelValues(name, "missed").Inc()
+}
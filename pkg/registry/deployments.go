@@ -0,0 +1,73 @@
+package registry
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+// deployment statuses
+const (
+	DeploymentBuilding  = "building"
+	DeploymentSucceeded = "succeeded"
+	DeploymentFailed    = "failed"
+)
+
+// deployment records a single build-and-register attempt for a function
+type Deployment struct {
+	ID          string
+	Function    string
+	Status      string
+	ImageTag    string
+	ImageDigest string
+	Artifact    string // code artifact digest, set instead of ImageDigest for buildless deploys
+	Version     int    // function version published by this deployment
+	Error       string
+	Logs        string
+	CreatedAt   time.Time
+	FinishedAt  *time.Time // nil until the deployment succeeded or failed
+}
+
+// newdeploymentid returns a random id for a deployment
+func NewDeploymentID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "dep-" + hex.EncodeToString(b)
+}
+
+// createdeployment stores a new deployment in the building state
+func (m *Manager) CreateDeployment(d Deployment) error {
+	query := `
+	INSERT INTO deployments (id, function, status, image_tag, image_digest, error, logs, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := m.db.Exec(query, d.ID, d.Function, d.Status, d.ImageTag, d.ImageDigest, d.Error, d.Logs, d.CreatedAt)
+	return err
+}
+
+// finishdeployment records the outcome and build logs of a deployment
+func (m *Manager) FinishDeployment(d Deployment) error {
+	query := `
+	UPDATE deployments SET status = ?, image_digest = ?, artifact = ?, version = ?, error = ?, logs = ?, finished_at = ?
+	WHERE id = ?`
+	_, err := m.db.Exec(query, d.Status, d.ImageDigest, d.Artifact, d.Version, d.Error, d.Logs, d.FinishedAt, d.ID)
+	return err
+}
+
+// getdeployment retrieves a deployment by id
+func (m *Manager) GetDeployment(id string) (*Deployment, error) {
+	query := `
+	SELECT id, function, status, image_tag, image_digest, artifact, version, error, logs, created_at, finished_at
+	FROM deployments WHERE id = ?`
+
+	var d Deployment
+	var finished sql.NullTime
+	err := m.db.QueryRow(query, id).Scan(&d.ID, &d.Function, &d.Status, &d.ImageTag, &d.ImageDigest, &d.Artifact, &d.Version, &d.Error, &d.Logs, &d.CreatedAt, &finished)
+	if err != nil {
+		return nil, err
+	}
+	if finished.Valid {
+		d.FinishedAt = &finished.Time
+	}
+	return &d, nil
+}
@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// invocation starts
+const (
+	StartCold = "cold"
+	StartWarm = "warm"
+)
+
+// invocation is one request to a function, as seen by the gateway
+type Invocation struct {
+	ID          string // assigned by the registry, unique per invocation
+	RequestID   string // X-Request-ID, chosen by the caller so not unique
+	Function    string
+	Version     int // version that served it, 0 if unknown
+	StartedAt   time.Time
+	Duration    time.Duration
+	Status      int
+	Start       string // StartCold or StartWarm
+	ContainerID string
+
+	// bodies are kept for a sample of invocations and for every error, nil otherwise
+	RequestBody       []byte
+	ResponseBody      []byte
+	RequestTruncated  bool
+	ResponseTruncated bool
+}
+
+// haspayload reports whether the bodies of the invocation were kept
+func (inv *Invocation) HasPayload() bool {
+	return inv.RequestBody != nil || inv.ResponseBody != nil
+}
+
+// invocationfilter narrows a history query, zero fields match everything
+type InvocationFilter struct {
+	Function  string
+	RequestID string
+	Since     time.Time
+	Until     time.Time
+	Errors    bool // only invocations with a 5xx status
+	Status    int  // only this exact status
+	Start     string
+	Limit     int
+}
+
+// newinvocationid returns a random id for an invocation
+func NewInvocationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "inv-" + hex.EncodeToString(b)
+}
+
+// recordinvocation stores an invocation, giving it a new id unless it has one
+// request ids come from callers, so a reused one is stored as a separate invocation
+func (m *Manager) RecordInvocation(inv Invocation) error {
+	if inv.ID == "" {
+		inv.ID = NewInvocationID()
+	}
+	query := `
+	INSERT INTO invocations (id, request_id, function, version, started_at, duration_ms, status, start, container_id,
+		request_body, response_body, request_truncated, response_truncated)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := m.db.Exec(query, inv.ID, inv.RequestID, inv.Function, inv.Version, inv.StartedAt.UTC(), float64(inv.Duration.Microseconds())/1000,
+		inv.Status, inv.Start, inv.ContainerID, inv.RequestBody, inv.ResponseBody, inv.RequestTruncated, inv.ResponseTruncated)
+	return err
+}
+
+const invocationColumns = `id, request_id, function, version, started_at, duration_ms, status, start, container_id,
+	request_body, response_body, request_truncated, response_truncated`
+
+func scanInvocation(s scanner) (*Invocation, error) {
+	var inv Invocation
+	var ms float64
+	err := s.Scan(&inv.ID, &inv.RequestID, &inv.Function, &inv.Version, &inv.StartedAt, &ms, &inv.Status, &inv.Start, &inv.ContainerID,
+		&inv.RequestBody, &inv.ResponseBody, &inv.RequestTruncated, &inv.ResponseTruncated)
+	if err != nil {
+		return nil, err
+	}
+	inv.Duration = time.Duration(ms * float64(time.Millisecond))
+	return &inv, nil
+}
+
+// getinvocation retrieves an invocation by its registry id
+func (m *Manager) GetInvocation(id string) (*Invocation, error) {
+	query := `SELECT ` + invocationColumns + ` FROM invocations WHERE id = ?`
+	return scanInvocation(m.db.QueryRow(query, id))
+}
+
+// listinvocations returns the invocations matching f, newest first
+func (m *Manager) ListInvocations(f InvocationFilter) ([]Invocation, error) {
+	var where []string
+	var args []interface{}
+	if f.Function != "" {
+		where = append(where, "function = ?")
+		args = append(args, f.Function)
+	}
+	if f.RequestID != "" {
+		where = append(where, "request_id = ?")
+		args = append(args, f.RequestID)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "started_at >= ?")
+		args = append(args, f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "started_at < ?")
+		args = append(args, f.Until.UTC())
+	}
+	if f.Errors {
+		where = append(where, "status >= 500")
+	}
+	if f.Status != 0 {
+		where = append(where, "status = ?")
+		args = append(args, f.Status)
+	}
+	if f.Start != "" {
+		where = append(where, "start = ?")
+		args = append(args, f.Start)
+	}
+
+	query := `SELECT ` + invocationColumns + ` FROM invocations`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY started_at DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invocations []Invocation
+	for rows.Next() {
+		inv, err := scanInvocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invocations = append(invocations, *inv)
+	}
+	return invocations, rows.Err()
+}
+
+// pruneinvocations deletes invocations that started before the given time
+// returns how many were deleted
+func (m *Manager) PruneInvocations(before time.Time) (int64, error) {
+	res, err := m.db.Exec(`DELETE FROM invocations WHERE started_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
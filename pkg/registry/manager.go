@@ -3,6 +3,7 @@ package registry
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -16,6 +17,50 @@ type Function struct {
 	CreatedAt   time.Time
 	MemoryLimit int64
 	Timeout     int
+	ImageDigest string // immutable image id recorded at build time
+	Version     int    // latest published version, 0 if never published
+	Artifact    string // code artifact digest for buildless functions, run on ImageTag
+
+	// replicas kept running at all times, optionally overridden by time of day
+	MinInstances int
+	Schedule     []ScheduleOverride
+
+	// autoscaling: in-flight requests per replica and the replica ceiling (0 uses the gateway default)
+	TargetConcurrency int
+	MaxInstances      int
+
+	// replicas are recycled after MaxLifetime seconds or MaxRequests requests, 0 disables
+	MaxLifetime int
+	MaxRequests int
+
+	// readiness probe for new containers, nil uses the gateway default
+	Readiness *ReadinessProbe
+
+	// namespace groups functions in usage reports
+	Namespace string
+
+	// fraction of invocations traced, nil uses the gateway default
+	TraceSampleRate *float64
+}
+
+// image returns the reference containers should be started from
+// the digest is preferred so a rebuilt :latest tag can't change a running deployment
+func (fn *Function) Image() string {
+	if fn.ImageDigest != "" {
+		return fn.ImageDigest
+	}
+	return fn.ImageTag
+}
+
+// defaultnamespace is used for functions that don't set one
+const DefaultNamespace = "default"
+
+// namespace returns the function's namespace, or the default one
+func (fn *Function) namespace() string {
+	if fn.Namespace == "" {
+		return DefaultNamespace
+	}
+	return fn.Namespace
 }
 
 // manager handles database interactions
@@ -48,42 +93,167 @@ func (m *Manager) initSchema() error {
 		created_at DATETIME,
 		memory_limit INTEGER,
 		timeout INTEGER
-	);`
-	_, err := m.db.Exec(query)
-	return err
+	);
+	CREATE TABLE IF NOT EXISTS deployments (
+		id TEXT PRIMARY KEY,
+		function TEXT,
+		status TEXT,
+		image_tag TEXT,
+		image_digest TEXT,
+		error TEXT,
+		logs TEXT,
+		created_at DATETIME,
+		finished_at DATETIME
+	);
+	CREATE TABLE IF NOT EXISTS function_versions (
+		function TEXT,
+		version INTEGER,
+		image_tag TEXT,
+		image_digest TEXT,
+		deployment_id TEXT,
+		created_at DATETIME,
+		PRIMARY KEY (function, version)
+	);
+	CREATE TABLE IF NOT EXISTS request_rates (
+		function TEXT,
+		bucket_start DATETIME,
+		count REAL,
+		PRIMARY KEY (function, bucket_start)
+	);
+	CREATE TABLE IF NOT EXISTS usage (
+		function TEXT,
+		hour DATETIME,
+		invocations INTEGER,
+		gb_seconds REAL,
+		idle_gb_seconds REAL,
+		PRIMARY KEY (function, hour)
+	);
+	CREATE TABLE IF NOT EXISTS invocations (
+		id TEXT PRIMARY KEY,
+		request_id TEXT,
+		function TEXT,
+		version INTEGER,
+		started_at DATETIME,
+		duration_ms REAL,
+		status INTEGER,
+		start TEXT,
+		container_id TEXT,
+		request_body BLOB,
+		response_body BLOB,
+		request_truncated INTEGER DEFAULT 0,
+		response_truncated INTEGER DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS invocations_function_started ON invocations (function, started_at);
+	CREATE INDEX IF NOT EXISTS invocations_request_id ON invocations (request_id);`
+	if _, err := m.db.Exec(query); err != nil {
+		return err
+	}
+
+	// columns added after the first release, older databases are upgraded in place
+	if err := m.addColumns("functions", map[string]string{
+		"image_digest":  "TEXT DEFAULT ''",
+		"version":       "INTEGER DEFAULT 0",
+		"artifact":      "TEXT DEFAULT ''",
+		"min_instances": "INTEGER DEFAULT 0",
+		"schedule":      "TEXT DEFAULT ''",
+
+		"target_concurrency": "INTEGER DEFAULT 0",
+		"max_instances":      "INTEGER DEFAULT 0",
+		"max_lifetime":       "INTEGER DEFAULT 0",
+		"max_requests":       "INTEGER DEFAULT 0",
+		"readiness":          "TEXT DEFAULT ''",
+		"namespace":          "TEXT DEFAULT 'default'",
+		"trace_sample_rate":  "REAL",
+	}); err != nil {
+		return err
+	}
+	if err := m.addColumns("function_versions", map[string]string{
+		"artifact": "TEXT DEFAULT ''",
+	}); err != nil {
+		return err
+	}
+	return m.addColumns("deployments", map[string]string{
+		"version":  "INTEGER DEFAULT 0",
+		"artifact": "TEXT DEFAULT ''",
+	})
+}
+
+// addcolumns adds any of the given columns that don't exist yet
+func (m *Manager) addColumns(table string, columns map[string]string) error {
+	for name, def := range columns {
+		_, err := m.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, name, def))
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return fmt.Errorf("failed to migrate %s.%s: %w", table, name, err)
+		}
+	}
+	return nil
 }
 
 // registerfunction adds or updates a function in the registry
 func (m *Manager) RegisterFunction(fn Function) error {
 	query := `
-	INSERT INTO functions (name, runtime, image_tag, created_at, memory_limit, timeout)
-	VALUES (?, ?, ?, ?, ?, ?)
+	INSERT INTO functions (name, runtime, image_tag, created_at, memory_limit, timeout, image_digest, min_instances, schedule, target_concurrency, max_instances, max_lifetime, max_requests, readiness, namespace, trace_sample_rate)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	ON CONFLICT(name) DO UPDATE SET
 		runtime=excluded.runtime,
 		image_tag=excluded.image_tag,
 		memory_limit=excluded.memory_limit,
-		timeout=excluded.timeout;
+		timeout=excluded.timeout,
+		image_digest=excluded.image_digest,
+		min_instances=excluded.min_instances,
+		schedule=excluded.schedule,
+		target_concurrency=excluded.target_concurrency,
+		max_instances=excluded.max_instances,
+		max_lifetime=excluded.max_lifetime,
+		max_requests=excluded.max_requests,
+		readiness=excluded.readiness,
+		namespace=excluded.namespace,
+		trace_sample_rate=excluded.trace_sample_rate;
 	`
-	_, err := m.db.Exec(query, fn.Name, fn.Runtime, fn.ImageTag, fn.CreatedAt, fn.MemoryLimit, fn.Timeout)
+	_, err := m.db.Exec(query, fn.Name, fn.Runtime, fn.ImageTag, fn.CreatedAt, fn.MemoryLimit, fn.Timeout, fn.ImageDigest,
+		fn.MinInstances, encodeSchedule(fn.Schedule), fn.TargetConcurrency, fn.MaxInstances, fn.MaxLifetime, fn.MaxRequests,
+		encodeReadiness(fn.Readiness), fn.namespace(), fn.TraceSampleRate)
 	return err
 }
 
-// getfunction retrieves a function by name
-func (m *Manager) GetFunction(name string) (*Function, error) {
-	query := `SELECT name, runtime, image_tag, created_at, memory_limit, timeout FROM functions WHERE name = ?`
-	row := m.db.QueryRow(query, name)
+// functioncolumns is the select list shared by every function query
+const functionColumns = `name, runtime, image_tag, created_at, memory_limit, timeout, image_digest, version, artifact, min_instances, schedule, target_concurrency, max_instances, max_lifetime, max_requests, readiness, namespace, trace_sample_rate`
 
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFunction(s scanner) (*Function, error) {
 	var fn Function
-	err := row.Scan(&fn.Name, &fn.Runtime, &fn.ImageTag, &fn.CreatedAt, &fn.MemoryLimit, &fn.Timeout)
+	var schedule, readiness string
+	var sampleRate sql.NullFloat64
+	err := s.Scan(&fn.Name, &fn.Runtime, &fn.ImageTag, &fn.CreatedAt, &fn.MemoryLimit, &fn.Timeout, &fn.ImageDigest, &fn.Version, &fn.Artifact, &fn.MinInstances, &schedule,
+		&fn.TargetConcurrency, &fn.MaxInstances, &fn.MaxLifetime, &fn.MaxRequests, &readiness, &fn.Namespace, &sampleRate)
 	if err != nil {
 		return nil, err
 	}
+	if fn.Schedule, err = decodeSchedule(schedule); err != nil {
+		return nil, fmt.Errorf("invalid schedule for %s: %w", fn.Name, err)
+	}
+	if fn.Readiness, err = decodeReadiness(readiness); err != nil {
+		return nil, fmt.Errorf("invalid readiness probe for %s: %w", fn.Name, err)
+	}
+	if sampleRate.Valid {
+		fn.TraceSampleRate = &sampleRate.Float64
+	}
 	return &fn, nil
 }
 
+// getfunction retrieves a function by name
+func (m *Manager) GetFunction(name string) (*Function, error) {
+	query := `SELECT ` + functionColumns + ` FROM functions WHERE name = ?`
+	return scanFunction(m.db.QueryRow(query, name))
+}
+
 // listfunctions returns all registered functions
 func (m *Manager) ListFunctions() ([]Function, error) {
-	query := `SELECT name, runtime, image_tag, created_at, memory_limit, timeout FROM functions`
+	query := `SELECT ` + functionColumns + ` FROM functions`
 	rows, err := m.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -92,11 +262,11 @@ func (m *Manager) ListFunctions() ([]Function, error) {
 
 	var functions []Function
 	for rows.Next() {
-		var fn Function
-		if err := rows.Scan(&fn.Name, &fn.Runtime, &fn.ImageTag, &fn.CreatedAt, &fn.MemoryLimit, &fn.Timeout); err != nil {
+		fn, err := scanFunction(rows)
+		if err != nil {
 			return nil, err
 		}
-		functions = append(functions, fn)
+		functions = append(functions, *fn)
 	}
 	return functions, nil
 }
@@ -0,0 +1,48 @@
+package registry
+
+import "time"
+
+// ratepoint is the number of requests a function received in one time bucket
+type RatePoint struct {
+	Start time.Time
+	Count float64
+}
+
+// saverequestrate stores (or overwrites) the request count of a bucket
+func (m *Manager) SaveRequestRate(function string, p RatePoint) error {
+	query := `
+	INSERT INTO request_rates (function, bucket_start, count)
+	VALUES (?, ?, ?)
+	ON CONFLICT(function, bucket_start) DO UPDATE SET count=excluded.count;`
+	_, err := m.db.Exec(query, function, p.Start.UTC(), p.Count)
+	return err
+}
+
+// loadrequestrates returns every stored bucket since the given time, by function
+func (m *Manager) LoadRequestRates(since time.Time) (map[string][]RatePoint, error) {
+	query := `
+	SELECT function, bucket_start, count FROM request_rates
+	WHERE bucket_start >= ? ORDER BY function, bucket_start`
+	rows, err := m.db.Query(query, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := make(map[string][]RatePoint)
+	for rows.Next() {
+		var function string
+		var p RatePoint
+		if err := rows.Scan(&function, &p.Start, &p.Count); err != nil {
+			return nil, err
+		}
+		rates[function] = append(rates[function], p)
+	}
+	return rates, rows.Err()
+}
+
+// prunerequestrates deletes buckets older than the given time
+func (m *Manager) PruneRequestRates(before time.Time) error {
+	_, err := m.db.Exec(`DELETE FROM request_rates WHERE bucket_start < ?`, before.UTC())
+	return err
+}
@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// readinessprobe overrides how the gateway decides a new container is ready
+// times are in seconds, zero fields keep the gateway defaults
+type ReadinessProbe struct {
+	Path         string  `yaml:"path" json:"path,omitempty"`
+	InitialDelay float64 `yaml:"initial_delay" json:"initial_delay,omitempty"`
+	Period       float64 `yaml:"period" json:"period,omitempty"`
+	Timeout      float64 `yaml:"timeout" json:"timeout,omitempty"` // per attempt
+	Backoff      float64 `yaml:"backoff" json:"backoff,omitempty"` // period multiplier after each failure
+	MaxPeriod    float64 `yaml:"max_period" json:"max_period,omitempty"`
+	Deadline     float64 `yaml:"deadline" json:"deadline,omitempty"` // total time allowed to become ready
+}
+
+// validate checks the probe settings
+func (p *ReadinessProbe) Validate() error {
+	if p.Path != "" && !strings.HasPrefix(p.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	for name, v := range map[string]float64{
+		"initial_delay": p.InitialDelay, "period": p.Period, "timeout": p.Timeout,
+		"max_period": p.MaxPeriod, "deadline": p.Deadline,
+	} {
+		if v < 0 {
+			return fmt.Errorf("%s can't be negative", name)
+		}
+	}
+	if p.Backoff != 0 && p.Backoff < 1 {
+		return fmt.Errorf("backoff must be at least 1")
+	}
+	return nil
+}
+
+// encodereadiness stores a probe as json text
+func encodeReadiness(p *ReadinessProbe) string {
+	if p == nil {
+		return ""
+	}
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+func decodeReadiness(s string) (*ReadinessProbe, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var p ReadinessProbe
+	if err := json.Unmarshal([]byte(s), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
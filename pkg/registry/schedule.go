@@ -0,0 +1,120 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// scheduleoverride raises or lowers a function's minimum instances during a daily time window
+type ScheduleOverride struct {
+	Days         []string `yaml:"days" json:"days"`   // mon..sun, "weekdays", "weekends"; empty means every day
+	Start        string   `yaml:"start" json:"start"` // "09:00", inclusive
+	End          string   `yaml:"end" json:"end"`     // "18:00", exclusive; may wrap past midnight
+	MinInstances int      `yaml:"min_instances" json:"min_instances"`
+}
+
+var dayNames = map[string][]time.Weekday{
+	"sun":      {time.Sunday},
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+// validate checks the days and times of an override
+func (o ScheduleOverride) Validate() error {
+	for _, d := range o.Days {
+		if _, ok := dayNames[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("unknown day %q", d)
+		}
+	}
+	if _, err := parseClock(o.Start); err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+	if _, err := parseClock(o.End); err != nil {
+		return fmt.Errorf("invalid end: %w", err)
+	}
+	if o.MinInstances < 0 {
+		return fmt.Errorf("min_instances can't be negative")
+	}
+	return nil
+}
+
+// matches reports whether t falls inside the override's window
+func (o ScheduleOverride) Matches(t time.Time) bool {
+	start, err1 := parseClock(o.Start)
+	end, err2 := parseClock(o.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+
+	// a window that wraps midnight belongs to the day it started on
+	day := t.Weekday()
+	inWindow := now >= start && now < end
+	if end <= start {
+		inWindow = now >= start || now < end
+		if now < end {
+			day = (day + 6) % 7
+		}
+	}
+	return inWindow && o.onDay(day)
+}
+
+func (o ScheduleOverride) onDay(day time.Weekday) bool {
+	if len(o.Days) == 0 {
+		return true
+	}
+	for _, d := range o.Days {
+		for _, wd := range dayNames[strings.ToLower(d)] {
+			if wd == day {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseclock parses "hh:mm" into an offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// mininstancesat returns the replica floor of a function at time t
+// the first matching schedule override wins, otherwise MinInstances applies
+func (fn *Function) MinInstancesAt(t time.Time) int {
+	for _, o := range fn.Schedule {
+		if o.Matches(t) {
+			return o.MinInstances
+		}
+	}
+	return fn.MinInstances
+}
+
+// encodeschedule stores a schedule as json text
+func encodeSchedule(s []ScheduleOverride) string {
+	if len(s) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func decodeSchedule(s string) ([]ScheduleOverride, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var out []ScheduleOverride
+	err := json.Unmarshal([]byte(s), &out)
+	return out, err
+}
@@ -0,0 +1,48 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+// monday 1 january 2024 plus the given days, hours and minutes
+func weekTime(day, hour, minute int) time.Time {
+	return time.Date(2024, time.January, 1+day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestScheduleOverrideWrapsPastMidnight(t *testing.T) {
+	friday := ScheduleOverride{Days: []string{"fri"}, Start: "22:00", End: "02:00"}
+
+	for when, want := range map[time.Time]bool{
+		weekTime(4, 23, 0): true,  // friday night
+		weekTime(5, 1, 59): true,  // early saturday, still friday's window
+		weekTime(5, 2, 0):  false, // end is exclusive
+		weekTime(4, 1, 0):  false, // early friday belongs to thursday
+		weekTime(3, 23, 0): false,
+	} {
+		if got := friday.Matches(when); got != want {
+			t.Errorf("Matches(%s) = %v, want %v", when.Format("Mon 15:04"), got, want)
+		}
+	}
+}
+
+func TestMinInstancesAtFirstMatchWins(t *testing.T) {
+	fn := &Function{
+		MinInstances: 1,
+		Schedule: []ScheduleOverride{
+			{Days: []string{"weekdays"}, Start: "09:00", End: "18:00", MinInstances: 5},
+			{Start: "00:00", End: "23:59", MinInstances: 0},
+		},
+	}
+
+	if got := fn.MinInstancesAt(weekTime(0, 10, 0)); got != 5 {
+		t.Errorf("monday 10:00: got %d, want 5", got)
+	}
+	if got := fn.MinInstancesAt(weekTime(5, 10, 0)); got != 0 {
+		t.Errorf("saturday 10:00: got %d, want 0", got)
+	}
+	// no override covers the last minute of the day
+	if got := fn.MinInstancesAt(weekTime(5, 23, 59)); got != 1 {
+		t.Errorf("saturday 23:59: got %d, want 1", got)
+	}
+}
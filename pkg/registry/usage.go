@@ -0,0 +1,76 @@
+package registry
+
+import (
+	"fmt"
+	"time"
+)
+
+// usage report groupings
+const (
+	ByFunction  = "function"
+	ByNamespace = "namespace"
+)
+
+// usagerecord is the metered usage of one function in one hour
+type UsageRecord struct {
+	Hour          time.Time
+	Invocations   int64
+	GBSeconds     float64 // invocation duration x configured memory
+	IdleGBSeconds float64 // warm containers waiting for requests x configured memory
+}
+
+// usagetotal is the usage of a function or namespace over a report period
+type UsageTotal struct {
+	Key           string
+	Invocations   int64
+	GBSeconds     float64
+	IdleGBSeconds float64
+}
+
+// addusage adds a rollup to the stored usage of its hour
+func (m *Manager) AddUsage(function string, r UsageRecord) error {
+	query := `
+	INSERT INTO usage (function, hour, invocations, gb_seconds, idle_gb_seconds)
+	VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT(function, hour) DO UPDATE SET
+		invocations=invocations+excluded.invocations,
+		gb_seconds=gb_seconds+excluded.gb_seconds,
+		idle_gb_seconds=idle_gb_seconds+excluded.idle_gb_seconds;`
+	_, err := m.db.Exec(query, function, r.Hour.UTC(), r.Invocations, r.GBSeconds, r.IdleGBSeconds)
+	return err
+}
+
+// queryusage totals the usage of every hour in [from, to), grouped by function or namespace
+func (m *Manager) QueryUsage(from, to time.Time, by string) ([]UsageTotal, error) {
+	var key string
+	switch by {
+	case ByFunction:
+		key = "u.function"
+	case ByNamespace:
+		// usage of deleted functions is kept under the default namespace
+		key = "COALESCE(NULLIF(f.namespace, ''), '" + DefaultNamespace + "')"
+	default:
+		return nil, fmt.Errorf("unknown grouping %q (want %s or %s)", by, ByFunction, ByNamespace)
+	}
+
+	query := `
+	SELECT ` + key + ` AS k, SUM(u.invocations), SUM(u.gb_seconds), SUM(u.idle_gb_seconds)
+	FROM usage u LEFT JOIN functions f ON f.name = u.function
+	WHERE u.hour >= ? AND u.hour < ?
+	GROUP BY k ORDER BY k`
+	rows, err := m.db.Query(query, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []UsageTotal
+	for rows.Next() {
+		var t UsageTotal
+		if err := rows.Scan(&t.Key, &t.Invocations, &t.GBSeconds, &t.IdleGBSeconds); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
@@ -0,0 +1,102 @@
+package registry
+
+import (
+	"fmt"
+	"time"
+)
+
+// functionversion is an immutable snapshot of a function's image
+type FunctionVersion struct {
+	Function     string
+	Version      int
+	ImageTag     string
+	ImageDigest  string
+	Artifact     string
+	DeploymentID string
+	CreatedAt    time.Time
+}
+
+// publishversion registers fn and records its image as the next version
+// returns the new version number
+func (m *Manager) PublishVersion(fn Function, deploymentID string) (int, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var version int
+	row := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) + 1 FROM function_versions WHERE function = ?`, fn.Name)
+	if err := row.Scan(&version); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO function_versions (function, version, image_tag, image_digest, artifact, deployment_id, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`, fn.Name, version, fn.ImageTag, fn.ImageDigest, fn.Artifact, deploymentID, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to record version: %w", err)
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO functions (name, runtime, image_tag, created_at, memory_limit, timeout, image_digest, version, artifact, min_instances, schedule, target_concurrency, max_instances, max_lifetime, max_requests, readiness, namespace, trace_sample_rate)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(name) DO UPDATE SET
+		runtime=excluded.runtime,
+		image_tag=excluded.image_tag,
+		memory_limit=excluded.memory_limit,
+		timeout=excluded.timeout,
+		image_digest=excluded.image_digest,
+		version=excluded.version,
+		artifact=excluded.artifact,
+		min_instances=excluded.min_instances,
+		schedule=excluded.schedule,
+		target_concurrency=excluded.target_concurrency,
+		max_instances=excluded.max_instances,
+		max_lifetime=excluded.max_lifetime,
+		max_requests=excluded.max_requests,
+		readiness=excluded.readiness,
+		namespace=excluded.namespace,
+		trace_sample_rate=excluded.trace_sample_rate;
+	`, fn.Name, fn.Runtime, fn.ImageTag, fn.CreatedAt, fn.MemoryLimit, fn.Timeout, fn.ImageDigest, version, fn.Artifact,
+		fn.MinInstances, encodeSchedule(fn.Schedule), fn.TargetConcurrency, fn.MaxInstances, fn.MaxLifetime, fn.MaxRequests,
+		encodeReadiness(fn.Readiness), fn.namespace(), fn.TraceSampleRate)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, tx.Commit()
+}
+
+// getversion retrieves a specific version of a function
+func (m *Manager) GetVersion(name string, version int) (*FunctionVersion, error) {
+	query := `
+	SELECT function, version, image_tag, image_digest, artifact, deployment_id, created_at
+	FROM function_versions WHERE function = ? AND version = ?`
+
+	var v FunctionVersion
+	err := m.db.QueryRow(query, name, version).Scan(&v.Function, &v.Version, &v.ImageTag, &v.ImageDigest, &v.Artifact, &v.DeploymentID, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// getfunctionversion returns a function as it was at a specific version
+// version 0 means the latest
+func (m *Manager) GetFunctionVersion(name string, version int) (*Function, error) {
+	fn, err := m.GetFunction(name)
+	if err != nil || version == 0 || version == fn.Version {
+		return fn, err
+	}
+
+	v, err := m.GetVersion(name, version)
+	if err != nil {
+		return nil, err
+	}
+	fn.ImageTag = v.ImageTag
+	fn.ImageDigest = v.ImageDigest
+	fn.Artifact = v.Artifact
+	fn.Version = v.Version
+	return fn, nil
+}
@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// the runner contract: every function container serves these on port 8080
+const (
+	HealthPath = "/health" // 200 once the runner is accepting requests
+	InvokePath = "/invoke" // runs the handler with the request body
+	LoadPath   = "/load"   // accepts a tar of the function directory and loads it (generic runners only)
+)
+
+// waitready polls the runner's health endpoint until it answers 200
+// returns false if it didn't become ready within attempts*interval
+func WaitReady(ctx context.Context, addr string, attempts int, interval time.Duration) bool {
+	p := Probe{Path: HealthPath, Period: interval, Attempts: attempts, Backoff: 1}
+	return p.Wait(ctx, addr) == nil
+}
+
+// load binds a generic runner to a function by sending it the function's code
+func Load(ctx context.Context, addr string, code io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s%s", addr, LoadPath), code)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to load code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to load code: runner returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// probe is a readiness check against a runner
+// it waits initialdelay, then polls path every period, growing the period by backoff
+// after each failure up to maxperiod, until the runner answers 200 or deadline passes
+type Probe struct {
+	Path         string
+	InitialDelay time.Duration
+	Period       time.Duration
+	Timeout      time.Duration // per attempt
+	Backoff      float64       // period multiplier after a failed attempt, 1 keeps it fixed
+	MaxPeriod    time.Duration
+	Deadline     time.Duration // give up after this long, counted from the start of wait
+	Attempts     int           // give up after this many attempts, 0 means only the deadline applies
+}
+
+// defaultprobe polls /health every 100ms backing off to 1s, for up to 30 seconds
+// slow imports get time to finish instead of failing after a couple of seconds
+func DefaultProbe() Probe {
+	return Probe{
+		Path:      HealthPath,
+		Period:    100 * time.Millisecond,
+		Timeout:   time.Second,
+		Backoff:   1.5,
+		MaxPeriod: time.Second,
+		Deadline:  30 * time.Second,
+	}
+}
+
+// wait blocks until the runner at addr is ready
+// the error describes the last failed attempt
+func (p Probe) Wait(ctx context.Context, addr string) error {
+	if p.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Deadline)
+		defer cancel()
+	}
+	path := p.Path
+	if path == "" {
+		path = HealthPath
+	}
+	url := fmt.Sprintf("http://%s%s", addr, path)
+
+	wait := p.InitialDelay
+	period := p.Period
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return p.failed(attempt-1, lastErr, ctx.Err())
+			case <-time.After(wait):
+			}
+		}
+
+		lastErr = p.check(ctx, url)
+		if lastErr == nil {
+			return nil
+		}
+		if p.Attempts > 0 && attempt >= p.Attempts {
+			return p.failed(attempt, lastErr, nil)
+		}
+
+		wait = period
+		if p.Backoff > 1 {
+			period = time.Duration(float64(period) * p.Backoff)
+			if p.MaxPeriod > 0 && period > p.MaxPeriod {
+				period = p.MaxPeriod
+			}
+		}
+	}
+}
+
+// check runs one attempt
+func (p Probe) check(ctx context.Context, url string) error {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", p.Path, resp.Status)
+	}
+	return nil
+}
+
+func (p Probe) failed(attempts int, last, ctxErr error) error {
+	if last == nil {
+		last = ctxErr
+	}
+	return fmt.Errorf("not ready after %d attempts: %v", attempts, last)
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startingRunner fails health checks with 503 until readyAfter of them have failed,
+// or for good if readyAfter is negative, and records when each check arrived
+type startingRunner struct {
+	readyAfter int
+
+	mu     sync.Mutex
+	checks []time.Time
+}
+
+func (s *startingRunner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, time.Now())
+	if s.readyAfter < 0 || len(s.checks) <= s.readyAfter {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+func serve(t *testing.T, runner *startingRunner) string {
+	server := httptest.NewServer(runner)
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestProbeBacksOffUpToMaxPeriod(t *testing.T) {
+	runner := &startingRunner{readyAfter: 3}
+	probe := Probe{Period: 10 * time.Millisecond, Backoff: 2, MaxPeriod: 25 * time.Millisecond}
+	if err := probe.Wait(context.Background(), serve(t, runner)); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	if len(runner.checks) != 4 {
+		t.Fatalf("%d checks, want 4", len(runner.checks))
+	}
+	for i, want := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond} {
+		if gap := runner.checks[i+1].Sub(runner.checks[i]); gap < want {
+			t.Errorf("check %d came %v after the previous one, want at least %v", i+2, gap, want)
+		}
+	}
+}
+
+func TestProbeGivesUp(t *testing.T) {
+	addr := serve(t, &startingRunner{readyAfter: -1})
+
+	start := time.Now()
+	err := Probe{Period: 10 * time.Millisecond, Deadline: 100 * time.Millisecond}.Wait(context.Background(), addr)
+	if err == nil || !strings.Contains(err.Error(), "not ready after") {
+		t.Errorf("past the deadline: Wait = %v, want a not ready error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait took %v with a 100ms deadline", elapsed)
+	}
+
+	err = Probe{Period: 10 * time.Millisecond, Attempts: 2}.Wait(context.Background(), addr)
+	if err == nil || !strings.Contains(err.Error(), "not ready after 2 attempts") {
+		t.Errorf("out of attempts: Wait = %v, want not ready after 2 attempts", err)
+	}
+}
@@ -0,0 +1,62 @@
+package simulate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultkeepalive is the reaper's timeout for functions without one
+const defaultKeepAlive = 10 * time.Second
+
+// policy is one keep-alive and pre-warm setup to evaluate
+type Policy struct {
+	Name       string        // shown in the report, the spec it was parsed from
+	KeepAlive  time.Duration // container timeout, also the hybrid policy's fallback
+	Hybrid     bool          // use the hybrid histogram policy instead of the fixed timeout
+	PauseAfter time.Duration // pause idle containers after this long, 0 disables
+	Predictor  bool          // run the built-in predictor and warm ahead of forecast traffic
+	Threshold  float64       // warm when the predictor forecasts more requests than this
+}
+
+// parsepolicy reads a policy spec: parts joined with '+', each one of
+//
+//	fixed[:keepalive]       fixed timeout, 10s by default
+//	hybrid[:keepalive]      hybrid histogram policy, falling back to keepalive
+//	pause:duration          pause idle containers after duration
+//	predictor:threshold     warm ahead of forecasts above threshold
+//
+// e.g. "fixed:60s", "hybrid+pause:2s" or "fixed:30s+predictor:5"
+func ParsePolicy(spec string) (Policy, error) {
+	p := Policy{Name: spec, KeepAlive: defaultKeepAlive}
+	for _, part := range strings.Split(spec, "+") {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(part), ":")
+		switch name {
+		case "fixed", "hybrid":
+			p.Hybrid = name == "hybrid"
+			if hasArg {
+				d, err := time.ParseDuration(arg)
+				if err != nil || d <= 0 {
+					return p, fmt.Errorf("policy %q: invalid keep-alive %q", spec, arg)
+				}
+				p.KeepAlive = d
+			}
+		case "pause":
+			d, err := time.ParseDuration(arg)
+			if err != nil || d <= 0 {
+				return p, fmt.Errorf("policy %q: pause needs a duration, e.g. pause:2s", spec)
+			}
+			p.PauseAfter = d
+		case "predictor":
+			threshold, err := strconv.ParseFloat(arg, 64)
+			if err != nil || threshold < 0 {
+				return p, fmt.Errorf("policy %q: predictor needs a threshold, e.g. predictor:5", spec)
+			}
+			p.Predictor, p.Threshold = true, threshold
+		default:
+			return p, fmt.Errorf("policy %q: unknown part %q (want fixed, hybrid, pause or predictor)", spec, part)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,324 @@
+package simulate
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nikhi/nanolambda/pkg/docker"
+	"github.com/nikhi/nanolambda/pkg/predictor"
+	"github.com/nikhi/nanolambda/pkg/reaper"
+)
+
+// tickinterval matches the reaper's cleanup loop
+const tickInterval = time.Second
+
+// config is what the simulator assumes about the platform, shared by every policy
+type Config struct {
+	ColdStart time.Duration    // latency a cold start adds to its request
+	Resume    time.Duration    // latency resuming a paused container adds
+	WarmupTTL time.Duration    // how long predictor warmups are kept without traffic
+	Predictor predictor.Config // forecast settings, the threshold comes from the policy
+}
+
+// defaultconfig assumes half-second cold starts and the gateway's predictor and warmup defaults
+func DefaultConfig() Config {
+	return Config{
+		ColdStart: 500 * time.Millisecond,
+		Resume:    10 * time.Millisecond,
+		WarmupTTL: 300 * time.Second,
+		Predictor: predictor.DefaultConfig(),
+	}
+}
+
+// result is how a policy did over a trace
+type Result struct {
+	Policy           string
+	Invocations      int
+	ColdStarts       int
+	Resumes          int           // requests that waited for a paused container
+	P99AddedLatency  time.Duration // cold start or resume latency at the 99th percentile
+	ContainerSeconds float64       // time containers existed, booting, busy, idle or paused
+	Warmups          int           // containers started by the predictor
+	WarmupHits       int
+	WarmupWasted     int
+}
+
+// coldstartrate is the fraction of invocations that found no container
+func (r Result) ColdStartRate() float64 {
+	if r.Invocations == 0 {
+		return 0
+	}
+	return float64(r.ColdStarts) / float64(r.Invocations)
+}
+
+// run replays a trace against a real reaper driven by a virtual clock
+// containers are fake: cold starts take config.ColdStart, pre-warms and warmups are ready at once
+func Run(events []Event, policy Policy, config Config) Result {
+	s := &simulation{
+		policy:  policy,
+		config:  config,
+		result:  Result{Policy: policy.Name},
+		buckets: make(map[string]map[int64]float64),
+	}
+	if len(events) == 0 {
+		return s.result
+	}
+	s.now = events[0].StartedAt.Truncate(tickInterval)
+	s.backend = &fakeBackend{now: s.clock, running: make(map[string]time.Time)}
+
+	s.reaper = reaper.NewManager(s.backend)
+	s.reaper.SetClock(s.clock)
+	s.reaper.SetLauncher(s.launch)
+	s.reaper.SetPauseAfter(policy.PauseAfter)
+	if policy.Hybrid {
+		s.reaper.SetPolicy(reaper.NewHybridPolicy(reaper.DefaultHybridConfig()))
+	}
+
+	for _, ev := range events {
+		s.open++
+		s.at(ev.StartedAt, func() { s.arrive(ev) })
+	}
+	s.at(s.now.Add(tickInterval), s.tick)
+	if policy.Predictor {
+		s.at(s.now, s.forecast)
+	}
+
+	for s.queue.Len() > 0 {
+		next := heap.Pop(&s.queue).(step)
+		s.now = next.at
+		next.run()
+	}
+	return s.finish()
+}
+
+// simulation is the state of one run
+type simulation struct {
+	policy  Policy
+	config  Config
+	reaper  *reaper.Manager
+	backend *fakeBackend
+
+	now   time.Time
+	queue steps
+	seq   int
+	open  int // invocations that haven't finished yet
+
+	added  []time.Duration
+	result Result
+
+	// per-function request counts for the predictor, bucket start (unix) -> requests
+	buckets map[string]map[int64]float64
+}
+
+func (s *simulation) clock() time.Time { return s.now }
+
+// at schedules run at t, steps at the same time run in the order they were scheduled
+func (s *simulation) at(t time.Time, run func()) {
+	s.seq++
+	heap.Push(&s.queue, step{at: t, seq: s.seq, run: run})
+}
+
+// arrive routes an invocation the way the gateway does
+func (s *simulation) arrive(ev Event) {
+	fn := ev.Function
+	s.reaper.RecordInvocation(fn)
+	s.count(fn)
+	s.result.Invocations++
+
+	resumes := s.backend.resumes()
+	addr, warm := s.reaper.GetContainer(context.Background(), fn)
+	if !warm {
+		// every request that finds nothing starts its own container, like the gateway
+		s.result.ColdStarts++
+		s.added = append(s.added, s.config.ColdStart)
+		id, addr := s.backend.start()
+		s.at(s.now.Add(s.config.ColdStart), func() {
+			s.reaper.Register(context.Background(), fn, reaper.ContainerInfo{ID: id, Address: addr, Timeout: s.policy.KeepAlive})
+			s.reaper.Acquire(fn, addr)
+			s.at(s.now.Add(ev.Duration), func() { s.release(fn, addr) })
+		})
+		return
+	}
+
+	var added time.Duration
+	if s.backend.resumes() > resumes {
+		s.result.Resumes++
+		added = s.config.Resume
+	}
+	s.added = append(s.added, added)
+	s.at(s.now.Add(added+ev.Duration), func() { s.release(fn, addr) })
+}
+
+func (s *simulation) release(fn, addr string) {
+	s.reaper.Release(fn, addr)
+	s.open--
+}
+
+// tick runs the reaper's cleanup loop once a second until the trace is done
+func (s *simulation) tick() {
+	s.reaper.Tick(context.Background())
+	s.reaper.Settle()
+	if s.open > 0 {
+		s.at(s.now.Add(tickInterval), s.tick)
+	}
+}
+
+// launch is the reaper's launcher for pre-warms and minimum instances
+func (s *simulation) launch(ctx context.Context, function, origin string) error {
+	id, addr := s.backend.start()
+	s.reaper.Register(ctx, function, reaper.ContainerInfo{ID: id, Address: addr, Timeout: s.policy.KeepAlive, Origin: origin})
+	return nil
+}
+
+// count is the predictor's record, one request in the current bucket
+func (s *simulation) count(fn string) {
+	bucket := s.now.Truncate(s.config.Predictor.Bucket).Unix()
+	if s.buckets[fn] == nil {
+		s.buckets[fn] = make(map[int64]float64)
+	}
+	s.buckets[fn][bucket]++
+}
+
+// forecast is one predictor cycle: forecast every function from its complete buckets
+// and warm the ones expected to pass the policy's threshold
+func (s *simulation) forecast() {
+	cfg := s.config.Predictor
+	functions := make([]string, 0, len(s.buckets))
+	for fn := range s.buckets {
+		functions = append(functions, fn)
+	}
+	sort.Strings(functions)
+
+	for _, fn := range functions {
+		predicted, ok := cfg.Predict(cfg.Series(s.buckets[fn], s.now))
+		if ok && predicted > s.policy.Threshold {
+			s.warm(fn)
+		}
+	}
+
+	if s.open > 0 {
+		s.at(s.now.Add(cfg.Interval), s.forecast)
+	}
+}
+
+// warm is the gateway's warmup of one replica: extend a running one or start a new one
+func (s *simulation) warm(fn string) {
+	if s.reaper.Replicas(fn) > 0 {
+		s.reaper.Touch(fn)
+		return
+	}
+	id, addr := s.backend.start()
+	s.reaper.Register(context.Background(), fn, reaper.ContainerInfo{ID: id, Address: addr, Timeout: s.config.WarmupTTL, Origin: reaper.OriginWarmup})
+	s.result.Warmups++
+}
+
+// finish charges the containers still running at the end of the trace and totals the run
+func (s *simulation) finish() Result {
+	r := s.result
+	r.ContainerSeconds = s.backend.seconds(s.now)
+
+	for _, stats := range s.reaper.WarmupStats() {
+		r.WarmupHits += stats.Hits
+		r.WarmupWasted += stats.Wasted
+	}
+
+	if len(s.added) > 0 {
+		sort.Slice(s.added, func(i, j int) bool { return s.added[i] < s.added[j] })
+		r.P99AddedLatency = s.added[int(math.Ceil(0.99*float64(len(s.added))))-1]
+	}
+	return r
+}
+
+// step is a scheduled action on the virtual clock
+type step struct {
+	at  time.Time
+	seq int
+	run func()
+}
+
+// steps is a min-heap of steps by time
+type steps []step
+
+func (q steps) Len() int { return len(q) }
+func (q steps) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+func (q steps) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *steps) Push(x any)   { *q = append(*q, x.(step)) }
+func (q *steps) Pop() any {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+// fakebackend stands in for docker: it starts nothing and only keeps the books
+// pre-warms start containers from the reaper's goroutines, so it locks
+type fakeBackend struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	next     int
+	running  map[string]time.Time // container id -> start
+	stopped  float64              // container-seconds of stopped containers
+	unpaused int
+}
+
+// start creates a container and returns its id and address
+func (b *fakeBackend) start() (string, string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.next++
+	id := fmt.Sprintf("%064x", b.next)
+	b.running[id] = b.now()
+	return id, fmt.Sprintf("sim-%d:8080", b.next)
+}
+
+func (b *fakeBackend) resumes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.unpaused
+}
+
+// seconds returns the container-seconds used until end, counting running containers up to it
+func (b *fakeBackend) seconds(end time.Time) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	total := b.stopped
+	for _, started := range b.running {
+		total += end.Sub(started).Seconds()
+	}
+	return total
+}
+
+func (b *fakeBackend) StartContainer(_ context.Context, _ docker.ContainerSpec) (string, string, error) {
+	id, addr := b.start()
+	return addr, id, nil
+}
+
+func (b *fakeBackend) StopContainer(_ context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if started, ok := b.running[id]; ok {
+		b.stopped += b.now().Sub(started).Seconds()
+		delete(b.running, id)
+	}
+	return nil
+}
+
+func (b *fakeBackend) PauseContainer(context.Context, string) error { return nil }
+
+func (b *fakeBackend) UnpauseContainer(context.Context, string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unpaused++
+	return nil
+}
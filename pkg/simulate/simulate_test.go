@@ -0,0 +1,63 @@
+package simulate
+
+import (
+	"testing"
+	"time"
+)
+
+// trace calls fn at 0s, 5s and 30s and other once at 1s
+func trace() []Event {
+	start := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	call := func(fn string, at time.Duration) Event {
+		return Event{Function: fn, StartedAt: start.Add(at), Duration: 100 * time.Millisecond}
+	}
+	return []Event{call("fn", 0), call("other", time.Second), call("fn", 5*time.Second), call("fn", 30*time.Second)}
+}
+
+func run(t *testing.T, spec string) Result {
+	t.Helper()
+	policy, err := ParsePolicy(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Run(trace(), policy, DefaultConfig())
+}
+
+func TestLongerKeepAliveTradesContainerTimeForColdStarts(t *testing.T) {
+	// the 30s call comes after fn's 10s timeout
+	short := run(t, "fixed:10s")
+	if short.Invocations != 4 || short.ColdStarts != 3 {
+		t.Errorf("fixed:10s: %d cold starts in %d invocations, want 3 in 4", short.ColdStarts, short.Invocations)
+	}
+	if short.P99AddedLatency != DefaultConfig().ColdStart {
+		t.Errorf("fixed:10s: p99 added latency %v, want a cold start", short.P99AddedLatency)
+	}
+	if short.ContainerSeconds != 28 {
+		t.Errorf("fixed:10s: %v container-seconds, want 28", short.ContainerSeconds)
+	}
+
+	// containers still running when the trace ends are charged up to then
+	long := run(t, "fixed:60s")
+	if long.ColdStarts != 2 || long.ContainerSeconds != 61 {
+		t.Errorf("fixed:60s: %d cold starts and %v container-seconds, want 2 and 61", long.ColdStarts, long.ContainerSeconds)
+	}
+}
+
+func TestPausedContainersResume(t *testing.T) {
+	// paused after 2s idle, the 5s call resumes instead of finding a running container
+	r := run(t, "fixed:10s+pause:2s")
+	if r.ColdStarts != 3 || r.Resumes != 1 {
+		t.Errorf("%d cold starts and %d resumes, want 3 and 1", r.ColdStarts, r.Resumes)
+	}
+	if r.ContainerSeconds != 28 {
+		t.Errorf("%v container-seconds, want 28 (paused containers still count)", r.ContainerSeconds)
+	}
+}
+
+func TestHybridFallsBackToItsKeepAlive(t *testing.T) {
+	// too few idle times to learn from
+	r := run(t, "hybrid:10s")
+	if r.ColdStarts != 3 || r.ContainerSeconds != 28 {
+		t.Errorf("%d cold starts and %v container-seconds, want fixed:10s's 3 and 28", r.ColdStarts, r.ContainerSeconds)
+	}
+}
@@ -0,0 +1,60 @@
+package simulate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// event is one invocation in a trace
+type Event struct {
+	Function  string
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// traceline is the jsonl form of an event, the same fields 'nanolambda history --json' writes
+type traceLine struct {
+	Function   string    `json:"function"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS float64   `json:"duration_ms"`
+}
+
+// readtrace reads one json invocation per line, sorted by start time
+// blank lines are skipped and unknown fields ignored, so history exports can be used as they are
+func ReadTrace(r io.Reader) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var l traceLine
+		if err := json.Unmarshal(line, &l); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if l.Function == "" || l.StartedAt.IsZero() {
+			return nil, fmt.Errorf("line %d: function and started_at are required", n)
+		}
+		if l.DurationMS < 0 {
+			return nil, fmt.Errorf("line %d: negative duration_ms", n)
+		}
+		events = append(events, Event{
+			Function:  l.Function,
+			StartedAt: l.StartedAt,
+			Duration:  time.Duration(l.DurationMS * float64(time.Millisecond)),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].StartedAt.Before(events[j].StartedAt) })
+	return events, nil
+}
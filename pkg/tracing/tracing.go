@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exporters
+const (
+	ExporterNone = ""
+	ExporterOTLP = "otlp" // otlp over http to Endpoint
+	ExporterFile = "file" // one json span per line in File, for offline use
+)
+
+// FunctionKey is the span attribute holding the invoked function, used for per-function sampling
+const FunctionKey = attribute.Key("faas.name")
+
+// config selects where spans go and how many are kept
+type Config struct {
+	Exporter   string
+	Endpoint   string  // otlp http url, e.g. http://localhost:4318; empty uses the OTEL_EXPORTER_OTLP_* variables
+	File       string  // output path of the file exporter
+	SampleRate float64 // fraction of invocations traced for functions without their own rate
+}
+
+// setup installs the global tracer provider and the w3c trace context propagator
+// the propagator is installed even with no exporter, so traceparent still reaches functions
+// returns the sampler, to update per-function rates, and a shutdown func that flushes spans
+func Setup(ctx context.Context, service string, config Config) (*Sampler, func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	sampler := NewSampler(config.SampleRate)
+	var exporter sdktrace.SpanExporter
+	switch config.Exporter {
+	case ExporterNone:
+		return sampler, func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(config.Endpoint))
+		}
+		e, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+		}
+		exporter = e
+	case ExporterFile:
+		f, err := os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		e, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		exporter = e
+	default:
+		return nil, nil, fmt.Errorf("unknown exporter %q (want %s or %s)", config.Exporter, ExporterOTLP, ExporterFile)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		// a sampled caller keeps the whole trace, otherwise the function's rate decides
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler, sdktrace.WithRemoteParentNotSampled(sampler))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", service))),
+	)
+	otel.SetTracerProvider(provider)
+	return sampler, provider.Shutdown, nil
+}
+
+// sampler keeps a per-function fraction of root spans, based on their FunctionKey attribute
+type Sampler struct {
+	mu       sync.RWMutex
+	fallback sdktrace.Sampler
+	rates    map[string]sdktrace.Sampler
+}
+
+// newsampler creates a sampler that keeps rate of the traces of every function
+func NewSampler(rate float64) *Sampler {
+	return &Sampler{
+		fallback: sdktrace.TraceIDRatioBased(rate),
+		rates:    make(map[string]sdktrace.Sampler),
+	}
+}
+
+// setrates replaces the per-function rates, functions not in the map use the default
+func (s *Sampler) SetRates(rates map[string]float64) {
+	samplers := make(map[string]sdktrace.Sampler, len(rates))
+	for function, rate := range rates {
+		samplers[function] = sdktrace.TraceIDRatioBased(rate)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rates = samplers
+}
+
+func (s *Sampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	s.mu.RLock()
+	sampler := s.fallback
+	for _, a := range p.Attributes {
+		if a.Key != FunctionKey {
+			continue
+		}
+		// pinned versions share the function's rate
+		name, _, _ := strings.Cut(a.Value.AsString(), "@")
+		if r, ok := s.rates[name]; ok {
+			sampler = r
+		}
+		break
+	}
+	s.mu.RUnlock()
+	return sampler.ShouldSample(p)
+}
+
+func (s *Sampler) Description() string {
+	return "PerFunctionSampler{" + s.fallback.Description() + "}"
+}
+
+// end records err on span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -11,9 +11,9 @@ class PrometheusClient:
         Fetches the request rate for a function over the last lookback_days.
         Returns a DataFrame suitable for Prophet (ds, y).
         """
-        # Query: rate(http_requests_total{function="name"}[5m])
-        # This gives requests per second. We multiply by 300 to get requests per 5 min block.
-        query = f'rate(http_requests_total{{function="{function_name}"}}[5m]) * 300'
+        # Query: sum(rate(http_requests_total{function="name"}[5m]))
+        # This gives requests per second across all status classes. We multiply by 300 to get requests per 5 min block.
+        query = f'sum(rate(http_requests_total{{function="{function_name}"}}[5m])) * 300'
         
         start_time = timedelta(days=lookback_days)
         
@@ -1,6 +1,9 @@
 import os
+import io
 import sys
+import tarfile
 import importlib.util
+import inspect
 from flask import Flask, request, jsonify
 import traceback
 
@@ -28,6 +31,21 @@ def load_user_function():
         traceback.print_exc()
         return False
 
+def wants_context(handler):
+    # handle(event, context) gets the invocation context, handle(event) doesn't
+    try:
+        return len(inspect.signature(handler).parameters) >= 2
+    except (TypeError, ValueError):
+        return False
+
+def invocation_context():
+    # The gateway's request ID and W3C trace context, so handlers can log and continue the trace
+    return {
+        "request_id": request.headers.get("X-Request-ID"),
+        "traceparent": request.headers.get("traceparent"),
+        "tracestate": request.headers.get("tracestate"),
+    }
+
 @app.route('/invoke', methods=['POST'])
 def invoke():
     if user_module is None:
@@ -39,7 +57,10 @@ def invoke():
         
         # Call the user's 'handle' function
         if hasattr(user_module, 'handle'):
-            result = user_module.handle(req_data)
+            if wants_context(user_module.handle):
+                result = user_module.handle(req_data, invocation_context())
+            else:
+                result = user_module.handle(req_data)
             return jsonify(result)
         else:
             return jsonify({"error": "Function 'handle' not found in handler.py"}), 500
@@ -48,6 +69,30 @@ def invoke():
         traceback.print_exc()
         return jsonify({"error": str(e)}), 500
 
+@app.route('/load', methods=['POST'])
+def load():
+    # Late binding: a generic pooled runner is given its function's code
+    # as a tar of the function directory on first use
+    if user_module is not None:
+        return jsonify({"error": "Function already loaded"}), 409
+
+    try:
+        with tarfile.open(fileobj=io.BytesIO(request.get_data()), mode="r:") as tar:
+            for member in tar.getmembers():
+                # links could point the next member outside the code directory
+                if member.issym() or member.islnk():
+                    return jsonify({"error": f"Links are not allowed in code archive: {member.name}"}), 400
+                target = os.path.realpath(os.path.join("/function", member.name))
+                if os.path.commonpath([target, "/function"]) != "/function":
+                    return jsonify({"error": f"Invalid path in code archive: {member.name}"}), 400
+            tar.extractall("/function")
+    except tarfile.TarError as e:
+        return jsonify({"error": f"Invalid code archive: {e}"}), 400
+
+    if not load_user_function():
+        return jsonify({"error": "Failed to load function"}), 500
+    return jsonify({"status": "loaded"}), 200
+
 @app.route('/health', methods=['GET'])
 def health():
     return jsonify({"status": "ready"}), 200
@@ -56,7 +101,8 @@ if __name__ == '__main__':
     # Load function on startup
     success = load_user_function()
     if not success:
-        print("WARNING: Failed to load user function on startup")
+        # Generic pooled runners start empty and get their code via /load
+        print("WARNING: Failed to load user function on startup (waiting for /load)")
     
     # Run on port 8080
     app.run(host='0.0.0.0', port=8080)
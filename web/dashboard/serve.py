@@ -1,14 +0,0 @@
-import http.server
-import socketserver
-import os
-
-PORT = 3000
-DIRECTORY = os.path.dirname(os.path.abspath(__file__))
-
-class Handler(http.server.SimpleHTTPRequestHandler):
-    def __init__(self, *args, **kwargs):
-        super().__init__(*args, directory=DIRECTORY, **kwargs)
-
-print(f"Serving Dashboard at http://localhost:{PORT}")
-with socketserver.TCPServer(("", PORT), Handler) as httpd:
-    httpd.serve_forever()
@@ -0,0 +1,8 @@
+package web
+
+import "embed"
+
+// dashboard holds the static dashboard, served by the gateway on /admin/dashboard/
+//
+//go:embed dashboard
+var Dashboard embed.FS